@@ -0,0 +1,167 @@
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/keepsea/goDetect/rules"
+	"github.com/keepsea/goDetect/types"
+)
+
+// Baseline 是一次扫描中各检查项采集到的规范化数据快照
+type Baseline struct {
+	CreatedAt string                      `json:"created_at"`
+	Hostname  string                      `json:"hostname"`
+	Artifacts map[string][]types.Artifact `json:"artifacts"` // 以检查项名称为键
+}
+
+// FromResults 从检查结果中提取基线数据。失败、超时或降级的检查项数据不完整，不会写入基线
+func FromResults(hostname string, results []types.CheckResult) *Baseline {
+	b := &Baseline{
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05 MST"),
+		Hostname:  hostname,
+		Artifacts: make(map[string][]types.Artifact),
+	}
+	for _, r := range results {
+		if !usable(r) {
+			continue
+		}
+		b.Artifacts[r.CheckName] = append(b.Artifacts[r.CheckName], r.Artifacts...)
+	}
+	for name := range b.Artifacts {
+		sortArtifacts(b.Artifacts[name])
+	}
+	return b
+}
+
+// Save 将基线写入文件
+func (b *Baseline) Save(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("无法创建基线目录 '%s': %w", dir, err)
+		}
+	}
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化基线失败: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return fmt.Errorf("写入基线文件 '%s' 失败: %w", path, err)
+	}
+	return nil
+}
+
+// Load 从文件中读取基线
+func Load(path string) (*Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("解析基线文件 '%s' 失败: %w", path, err)
+	}
+	return &b, nil
+}
+
+// Compare 将检查结果与基线比对，把新增、删除和变更的数据作为发现追加到对应结果中，返回发生漂移的数据条数。
+// 只有基线中存在且本次成功采集的检查项才会参与比对，以免数据缺失被误报为"删除"
+func (b *Baseline) Compare(results []types.CheckResult) int {
+	total := 0
+	for i := range results {
+		r := &results[i]
+		baseArtifacts, ok := b.Artifacts[r.CheckName]
+		if !ok || !usable(*r) {
+			continue
+		}
+		drift := diff(baseArtifacts, r.Artifacts)
+		if len(drift) == 0 {
+			r.Result += " (与基线一致)"
+			continue
+		}
+		total += len(drift)
+		r.Findings = append(r.Findings, drift...)
+		r.Status = types.StatusSuspicious
+		r.Result = fmt.Sprintf("与基线 (%s) 相比发现 %d 处变化; %s", b.CreatedAt, len(drift), r.Result)
+	}
+	return total
+}
+
+// usable 判断检查结果中的采集数据是否完整可用。降级的检查项只采集到部分数据，参与比对会把缺失的条目误报为"删除"；
+// 容器的生命周期较短且与宿主机数据同名，不参与基线
+func usable(r types.CheckResult) bool {
+	return r.Container == nil &&
+		r.Status != types.StatusError && r.Status != types.StatusTimedOut && r.Status != types.StatusSkipped &&
+		r.Status != types.StatusDegraded
+}
+
+// diff 计算两组数据之间的差异，重复的键只报告一次
+func diff(base, current []types.Artifact) []rules.Finding {
+	baseMap := toMap(base)
+	currentMap := toMap(current)
+	var findings []rules.Finding
+
+	seen := make(map[string]bool)
+	for _, a := range current {
+		if seen[a.Key] {
+			continue
+		}
+		seen[a.Key] = true
+		old, existed := baseMap[a.Key]
+		switch {
+		case !existed:
+			findings = append(findings, driftFinding("Baseline_Drift_Added", "与基线相比新增的条目", "Medium",
+				fmt.Sprintf("新增: %s", describe(a.Key, a.Value))))
+		case old != a.Value:
+			findings = append(findings, driftFinding("Baseline_Drift_Changed", "与基线相比属性发生变化的条目", "Medium",
+				fmt.Sprintf("变更: %s (基线: %s -> 当前: %s)", a.Key, old, a.Value)))
+		}
+	}
+	for _, a := range base {
+		if _, exists := currentMap[a.Key]; exists || seen[a.Key] {
+			continue
+		}
+		seen[a.Key] = true
+		findings = append(findings, driftFinding("Baseline_Drift_Removed", "与基线相比被删除的条目", "Low",
+			fmt.Sprintf("删除: %s", describe(a.Key, a.Value))))
+	}
+	return findings
+}
+
+func driftFinding(name, description, riskLevel, line string) rules.Finding {
+	return rules.Finding{
+		Source:      "Baseline",
+		Name:        name,
+		Description: description,
+		RiskLevel:   riskLevel,
+		MatchedLine: line,
+	}
+}
+
+func describe(key, value string) string {
+	if value == "" {
+		return key
+	}
+	return fmt.Sprintf("%s (%s)", key, value)
+}
+
+func toMap(artifacts []types.Artifact) map[string]string {
+	m := make(map[string]string, len(artifacts))
+	for _, a := range artifacts {
+		m[a.Key] = a.Value
+	}
+	return m
+}
+
+func sortArtifacts(artifacts []types.Artifact) {
+	sort.Slice(artifacts, func(i, j int) bool {
+		if artifacts[i].Key != artifacts[j].Key {
+			return artifacts[i].Key < artifacts[j].Key
+		}
+		return artifacts[i].Value < artifacts[j].Value
+	})
+}
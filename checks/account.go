@@ -2,29 +2,55 @@ package checks
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io/ioutil"
-	"regexp"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/keepsea/goDetect/core"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
+	"github.com/keepsea/goDetect/utmp"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "RootAccountsCheck", Category: "account", Tags: []string{"file", "baseline", "container"},
+		New: func(o core.Options) core.Checker { return RootAccountsCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+	core.Register(core.Registration{Name: "EmptyPasswordAccountsCheck", Category: "account", Tags: []string{"file", "container"},
+		New: func(o core.Options) core.Checker {
+			return EmptyPasswordAccountsCheck{RuleEngine: o.RuleEngine, Root: o.Root}
+		}})
+	core.Register(core.Registration{Name: "SudoersCheck", Category: "account", Tags: []string{"file", "rules", "container"},
+		Fields: []string{"file", "lineno"},
+		New:    func(o core.Options) core.Checker { return SudoersCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+	core.Register(core.Registration{Name: "LastLoginsCheck", Category: "account", Tags: []string{"login", "ioc"},
+		New: func(o core.Options) core.Checker {
+			return LastLoginsCheck{RuleEngine: o.RuleEngine, Limit: o.LoginLimit, Root: o.Root}
+		}})
+	core.Register(core.Registration{Name: "FailedLoginsCheck", Category: "account", Tags: []string{"login", "rules"},
+		Fields: []string{"time", "user", "tty", "from", "pid", "type"},
+		New:    func(o core.Options) core.Checker { return FailedLoginsCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+}
+
 // --- RootAccountsCheck ---
 type RootAccountsCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string // 离线分析时被检查文件系统的根目录，为空表示当前系统
 }
 
 func (c RootAccountsCheck) Name() string { return "RootAccountsCheck" }
-func (c RootAccountsCheck) Execute() []types.CheckResult {
+func (c RootAccountsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "👤 账号安全",
 	}
-	content, err := ioutil.ReadFile("/etc/passwd")
+	content, err := ioutil.ReadFile(utils.HostPath(c.Root, "/etc/passwd"))
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法读取 /etc/passwd 文件: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法读取 /etc/passwd 文件: "+err.Error()
 		return []types.CheckResult{cr}
 	}
 	cr.Details = "--- /etc/passwd 内容 ---\n" + string(content)
@@ -36,11 +62,17 @@ func (c RootAccountsCheck) Execute() []types.CheckResult {
 		if len(parts) > 3 && parts[2] == "0" {
 			rootUsers = append(rootUsers, parts[0])
 		}
+		if len(parts) >= 7 {
+			cr.Artifacts = append(cr.Artifacts, types.Artifact{
+				Key:   parts[0],
+				Value: fmt.Sprintf("uid=%s gid=%s home=%s shell=%s", parts[2], parts[3], parts[5], parts[6]),
+			})
+		}
 	}
 	if len(rootUsers) == 1 && rootUsers[0] == "root" {
-		cr.IsSuspicious, cr.Result = false, "正常"
+		cr.Status, cr.Result = types.StatusOK, "正常"
 	} else {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个UID为0的账户", len(rootUsers))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个UID为0的账户", len(rootUsers))
 	}
 	return []types.CheckResult{cr}
 }
@@ -48,33 +80,58 @@ func (c RootAccountsCheck) Execute() []types.CheckResult {
 // --- EmptyPasswordAccountsCheck ---
 type EmptyPasswordAccountsCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string
 }
 
 func (c EmptyPasswordAccountsCheck) Name() string { return "EmptyPasswordAccountsCheck" }
-func (c EmptyPasswordAccountsCheck) Execute() []types.CheckResult {
+func (c EmptyPasswordAccountsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "👤 账号安全",
 	}
-	out, err := utils.RunCommand("getent", "shadow")
-	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法执行 'getent shadow' 命令: "+err.Error()
-		return []types.CheckResult{cr}
+	var out string
+	if c.Root != "" {
+		// 离线模式下 getent 只能查询当前系统，直接读取镜像中的 shadow 文件
+		content, err := ioutil.ReadFile(utils.HostPath(c.Root, "/etc/shadow"))
+		if err != nil {
+			cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法读取 /etc/shadow 文件: "+err.Error()
+			return []types.CheckResult{cr}
+		}
+		out = string(content)
+		cr.Details = "--- /etc/shadow 内容 ---\n" + out
+	} else {
+		var err error
+		out, err = utils.RunCommand(ctx, "getent", "shadow")
+		if err != nil {
+			cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法执行 'getent shadow' 命令: "+err.Error()+partialOutput(out)
+			return []types.CheckResult{cr}
+		}
+		cr.Details = "--- 'getent shadow' 原始输出 ---\n" + out
 	}
-	cr.Details = "--- 'getent shadow' 原始输出 ---\n" + out
 
-	var emptyPassUsers []string
+	// 密码字段为空的账户无需密码即可登录；以 "!" 或 "*" 开头的账户已被锁定 (如 "!", "!!", "*", "!$6$...")，
+	// 无法通过密码登录，仅作为参考信息列出
+	var emptyPassUsers, lockedUsers []string
 	scanner := bufio.NewScanner(strings.NewReader(out))
 	for scanner.Scan() {
 		parts := strings.Split(scanner.Text(), ":")
-		if len(parts) > 1 && (parts[1] == "" || parts[1] == "!" || parts[1] == "!!" || parts[1] == "*") {
+		if len(parts) < 2 {
+			continue
+		}
+		switch {
+		case parts[1] == "":
 			emptyPassUsers = append(emptyPassUsers, parts[0])
+		case strings.HasPrefix(parts[1], "!") || strings.HasPrefix(parts[1], "*"):
+			lockedUsers = append(lockedUsers, parts[0])
 		}
 	}
+	if len(lockedUsers) > 0 {
+		cr.Details += "\n--- 已锁定的账户 (仅供参考) ---\n" + strings.Join(lockedUsers, "\n")
+	}
 
 	if len(emptyPassUsers) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个空密码或被锁定的账户", len(emptyPassUsers))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个空密码账户: %s", len(emptyPassUsers), strings.Join(emptyPassUsers, ", "))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现空密码账户"
+		cr.Status, cr.Result = types.StatusOK, "未发现空密码账户"
 	}
 	return []types.CheckResult{cr}
 }
@@ -82,97 +139,230 @@ func (c EmptyPasswordAccountsCheck) Execute() []types.CheckResult {
 // --- SudoersCheck ---
 type SudoersCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string
 }
 
 func (c SudoersCheck) Name() string { return "SudoersCheck" }
-func (c SudoersCheck) Execute() []types.CheckResult {
+func (c SudoersCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "👤 账号安全",
 	}
 	var contentBuilder strings.Builder
-	sudoersContent, _ := ioutil.ReadFile("/etc/sudoers")
+	var failedFiles []string
+	var records []rules.Record
+	sudoersContent, err := ioutil.ReadFile(utils.HostPath(c.Root, "/etc/sudoers"))
+	if err != nil {
+		failedFiles = append(failedFiles, "/etc/sudoers")
+	}
 	contentBuilder.WriteString("--- /etc/sudoers 内容 ---\n" + string(sudoersContent) + "\n\n")
-	files, _ := ioutil.ReadDir("/etc/sudoers.d/")
+	records = append(records, sudoersRecords("/etc/sudoers", string(sudoersContent))...)
+	files, err := ioutil.ReadDir(utils.HostPath(c.Root, "/etc/sudoers.d/"))
+	if err != nil && !os.IsNotExist(err) {
+		failedFiles = append(failedFiles, "/etc/sudoers.d/")
+	}
 	for _, f := range files {
 		filePath := "/etc/sudoers.d/" + f.Name()
-		fileContent, _ := ioutil.ReadFile(filePath)
+		fileContent, err := ioutil.ReadFile(utils.HostPath(c.Root, filePath))
+		if err != nil {
+			failedFiles = append(failedFiles, filePath)
+		}
 		contentBuilder.WriteString(fmt.Sprintf("--- 文件: %s ---\n%s\n", filePath, string(fileContent)))
+		records = append(records, sudoersRecords(filePath, string(fileContent))...)
 	}
 	cr.Details = contentBuilder.String()
-	findings := c.RuleEngine.Match("SudoersCheck", cr.Details)
+	findings := c.RuleEngine.MatchRecords("SudoersCheck", records)
 	cr.Findings = findings
 
 	if len(findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 条可疑Sudoers配置", len(findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 条可疑Sudoers配置", len(findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现高危Sudoers配置"
+		cr.Status, cr.Result = types.StatusOK, "未发现高危Sudoers配置"
 	}
+	degradeOnFailures(&cr, failedFiles)
 	return []types.CheckResult{cr}
 }
 
+// sudoersRecords 将 sudoers 文件中的有效配置行 (非空、非注释) 转换为记录，字段包括 file 和 lineno。
+// 以 # 开头的 #include 和 #includedir 是指令而不是注释，会被保留
+func sudoersRecords(file, content string) []rules.Record {
+	var records []rules.Record
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || (strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "#include")) {
+			continue
+		}
+		records = append(records, rules.NewRecord(trimmed, "file", file, "lineno", strconv.Itoa(i+1)))
+	}
+	return records
+}
+
 // --- LastLoginsCheck ---
 type LastLoginsCheck struct {
 	RuleEngine *rules.RuleEngine
 	Limit      int
+	Root       string
 }
 
-func (c LastLoginsCheck) Name() string {
-	return fmt.Sprintf("LastLoginsCheck", c.Limit)
-}
-func (c LastLoginsCheck) Execute() []types.CheckResult {
+func (c LastLoginsCheck) Name() string { return "LastLoginsCheck" }
+func (c LastLoginsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
-		Category: "👤 账号安全",
+		Category:    "👤 账号安全",
+		Description: fmt.Sprintf("检查最近%d条登录记录", c.Limit),
+	}
+	// 直接解析 utmp 格式的登录记录，不依赖 last 命令及其随语言环境变化的输出格式
+	records, err := utmp.ReadFile(utils.HostPath(c.Root, "/var/log/wtmp"))
+	if os.IsNotExist(err) {
+		cr.Status, cr.Result, cr.Details = types.StatusSkipped, "登录记录文件不存在", "未找到 /var/log/wtmp，系统可能未启用登录记录。"
+		return []types.CheckResult{cr}
 	}
-	out, err := utils.RunCommand("last", "-n", fmt.Sprintf("%d", c.Limit), "-a")
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法执行 'last' 命令: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法读取 /var/log/wtmp: "+err.Error()
 		return []types.CheckResult{cr}
 	}
-	cr.Details = "--- 'last' 原始输出 ---\n" + out
 
-	re := regexp.MustCompile(`(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})`)
-	scanner := bufio.NewScanner(strings.NewReader(out))
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := re.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			ip := matches[1]
-			findings := c.RuleEngine.MatchIOC("ip", ip)
-			cr.Findings = append(cr.Findings, findings...)
+	var failedSources []string
+	var builder strings.Builder
+	logins := userLogins(records)
+	if c.Limit > 0 && len(logins) > c.Limit {
+		logins = logins[len(logins)-c.Limit:]
+	}
+	builder.WriteString(fmt.Sprintf("--- 最近的登录记录 (/var/log/wtmp，共 %d 条，按时间倒序) ---\n", len(logins)))
+	for i := len(logins) - 1; i >= 0; i-- {
+		builder.WriteString(c.checkLogin(&cr, logins[i]) + "\n")
+	}
+
+	sessions, err := utmp.ReadFile(utils.HostPath(c.Root, "/var/run/utmp"))
+	if err != nil && !os.IsNotExist(err) {
+		failedSources = append(failedSources, "/var/run/utmp")
+	}
+	builder.WriteString("\n--- 当前登录会话 (/var/run/utmp) ---\n")
+	for _, r := range userLogins(sessions) {
+		builder.WriteString(c.checkLogin(&cr, r) + "\n")
+	}
+
+	users := userNames(c.Root)
+	var uids []int
+	for uid := range users {
+		uids = append(uids, uid)
+	}
+	sort.Ints(uids)
+	lastlog, err := utmp.ReadLastlog(utils.HostPath(c.Root, "/var/log/lastlog"), uids)
+	if err != nil && !os.IsNotExist(err) {
+		failedSources = append(failedSources, "/var/log/lastlog")
+	}
+	builder.WriteString("\n--- 各用户最近一次登录 (/var/log/lastlog) ---\n")
+	for _, e := range lastlog {
+		at := e.Time.Format("2006-01-02 15:04:05")
+		rec := rules.NewRecord(fmt.Sprintf("%s user=%s tty=%s from=%s", at, users[e.UID], e.Line, orDash(e.Host)),
+			"time", at, "user", users[e.UID], "tty", e.Line, "from", orDash(e.Host))
+		builder.WriteString(rec.Line() + "\n")
+		if addr, err := netip.ParseAddr(e.Host); err == nil {
+			cr.Findings = append(cr.Findings, matchLoginIP(c.RuleEngine, addr, rec)...)
 		}
 	}
+	cr.Details = builder.String()
 
 	if len(cr.Findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个来自可疑IP的登录", len(cr.Findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个来自可疑IP的登录", len(cr.Findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现来自已知可疑IP的登录"
+		cr.Status, cr.Result = types.StatusOK, "未发现来自已知可疑IP的登录"
 	}
+	degradeOnFailures(&cr, failedSources)
 	return []types.CheckResult{cr}
 }
 
+// checkLogin 格式化一条登录记录，并使用IOC对其来源IP进行匹配
+func (c LastLoginsCheck) checkLogin(cr *types.CheckResult, r utmp.Record) string {
+	rec := loginRecord(r)
+	if addr, ok := r.SourceIP(); ok {
+		cr.Findings = append(cr.Findings, matchLoginIP(c.RuleEngine, addr, rec)...)
+	}
+	return rec.Line()
+}
+
 // --- FailedLoginsCheck ---
 type FailedLoginsCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string
 }
 
 func (c FailedLoginsCheck) Name() string { return "FailedLoginsCheck" }
-func (c FailedLoginsCheck) Execute() []types.CheckResult {
+func (c FailedLoginsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "👤 账号安全",
 	}
-	out, err := utils.RunCommand("lastb")
+	records, err := utmp.ReadFile(utils.HostPath(c.Root, "/var/log/btmp"))
+	if os.IsNotExist(err) {
+		cr.Status, cr.Result, cr.Details = types.StatusSkipped, "失败登录记录文件不存在", "未找到 /var/log/btmp，系统可能未启用失败登录记录。"
+		return []types.CheckResult{cr}
+	}
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败或无权限", "无法执行 'lastb' 命令: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败或无权限", "无法读取 /var/log/btmp: "+err.Error()
 		return []types.CheckResult{cr}
 	}
-	cr.Details = "--- 'lastb' 原始输出 ---\n" + out
-	findings := c.RuleEngine.Match("FailedLoginsCheck", cr.Details)
-	cr.Findings = findings
+	var lines []string
+	var loginRecords []rules.Record
+	var iocFindings []rules.Finding
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := loginRecord(records[i])
+		lines = append(lines, rec.Line())
+		loginRecords = append(loginRecords, rec)
+		if addr, ok := records[i].SourceIP(); ok {
+			iocFindings = append(iocFindings, matchLoginIP(c.RuleEngine, addr, rec)...)
+		}
+	}
+	cr.Details = fmt.Sprintf("--- 失败的登录记录 (/var/log/btmp，共 %d 条，按时间倒序) ---\n", len(lines)) + strings.Join(lines, "\n")
+	findings := c.RuleEngine.MatchRecords("FailedLoginsCheck", loginRecords)
+	cr.Findings = append(findings, iocFindings...)
 
 	if len(findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 种暴力破解嫌疑", len(findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 种暴力破解嫌疑", len(findings))
+	} else if len(iocFindings) > 0 {
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 次来自可疑IP的失败登录", len(iocFindings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现明显的暴力破解行为"
+		cr.Status, cr.Result = types.StatusOK, "未发现明显的暴力破解行为"
 	}
 	return []types.CheckResult{cr}
 }
+
+// userLogins 从 utmp 记录中筛选出用户登录记录
+func userLogins(records []utmp.Record) []utmp.Record {
+	var logins []utmp.Record
+	for _, r := range records {
+		if r.Type == utmp.UserProcess {
+			logins = append(logins, r)
+		}
+	}
+	return logins
+}
+
+// loginRecord 将登录记录转换为供规则匹配的记录，字段包括 time、user、tty、from、pid 和 type。
+// 文本形式中来源IP位于时间之后，便于按行提取
+func loginRecord(r utmp.Record) rules.Record {
+	from := r.Host
+	if addr, ok := r.SourceIP(); ok && from == "" {
+		from = addr.String()
+	}
+	at := r.Time.Format("2006-01-02 15:04:05")
+	line := fmt.Sprintf("%s user=%s tty=%s from=%s pid=%d type=%s", at, orDash(r.User), orDash(r.Line), orDash(from), r.PID, r.Type)
+	return rules.NewRecord(line,
+		"time", at, "user", orDash(r.User), "tty", orDash(r.Line), "from", orDash(from),
+		"pid", strconv.Itoa(r.PID), "type", r.Type.String())
+}
+
+// matchLoginIP 使用IOC匹配登录来源IP，并在匹配结果中附带完整的登录记录
+func matchLoginIP(engine *rules.RuleEngine, addr netip.Addr, rec rules.Record) []rules.Finding {
+	findings := engine.MatchIOC("ip", addr.Unmap().String())
+	for i := range findings {
+		findings[i].MatchedLine += " (登录记录: " + rec.Line() + ")"
+		findings[i].Record = rec
+	}
+	return findings
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+package checks
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/keepsea/goDetect/core"
+	"github.com/keepsea/goDetect/rules"
+	"github.com/keepsea/goDetect/types"
+	"github.com/keepsea/goDetect/utils"
+)
+
+func init() {
+	core.Register(core.Registration{Name: "AuditLogCheck", Category: "log", Tags: []string{"file", "rules"},
+		New: func(o core.Options) core.Checker { return AuditLogCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+}
+
+// auditLogPath 是 auditd 默认的日志文件
+const auditLogPath = "/var/log/audit/audit.log"
+
+// --- AuditLogCheck ---
+type AuditLogCheck struct {
+	RuleEngine *rules.RuleEngine
+	Root       string
+}
+
+func (c AuditLogCheck) Name() string { return "AuditLogCheck" }
+func (c AuditLogCheck) Execute(ctx context.Context) []types.CheckResult {
+	cr := types.CheckResult{
+		Category: "📜 日志审计",
+	}
+	f, err := os.Open(utils.HostPath(c.Root, auditLogPath))
+	if os.IsNotExist(err) {
+		cr.Status, cr.Result, cr.Details = types.StatusSkipped, "审计日志不存在", "未找到 "+auditLogPath+"，系统可能未启用 auditd。"
+		return []types.CheckResult{cr}
+	}
+	if err != nil {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败或无权限", "无法读取 "+auditLogPath+": "+err.Error()
+		return []types.CheckResult{cr}
+	}
+	defer f.Close()
+
+	// 审计记录的每个字段都是 key=value 形式，直接作为规则匹配的字段
+	var records []rules.Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // EXECVE 记录可能包含很长的命令行
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			break
+		}
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			records = append(records, auditRecord(line))
+		}
+	}
+	var failed []string
+	if err := scanner.Err(); err != nil {
+		failed = append(failed, auditLogPath)
+	}
+
+	cr.Findings = c.RuleEngine.MatchRecords("AuditLogCheck", records)
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("--- %s (共 %d 条审计记录，以下仅列出命中规则的记录) ---\n", auditLogPath, len(records)))
+	for _, finding := range cr.Findings {
+		builder.WriteString(finding.MatchedLine + "\n")
+	}
+	cr.Details = builder.String()
+
+	if len(cr.Findings) > 0 {
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 条可疑的审计记录", len(cr.Findings))
+	} else {
+		cr.Status, cr.Result = types.StatusOK, "未发现可疑的审计记录"
+	}
+	degradeOnFailures(&cr, failed)
+	return []types.CheckResult{cr}
+}
+
+// execveArgRe 匹配 EXECVE 记录中的参数字段 a0、a1 ...
+var execveArgRe = regexp.MustCompile(`^a[0-9]+$`)
+
+// auditRecord 将一行审计日志转换为记录。带引号的值会去除引号；auditd 对可能含有特殊字符的字符串
+// (如 proctitle、EXECVE 的参数) 使用十六进制编码且不加引号，这些值会被解码，参数间的 NUL 替换为空格
+func auditRecord(line string) rules.Record {
+	rec := rules.Record(rules.ParseFields(line))
+	for k, v := range rec {
+		if k == "line" {
+			continue
+		}
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			rec[k] = v[1 : len(v)-1]
+			continue
+		}
+		// 嵌套在 msg='...' 中的最后一个字段会带上外层的单引号
+		v = strings.TrimSuffix(v, "'")
+		rec[k] = v
+		if k == "proctitle" || k == "name" || k == "cwd" || k == "comm" || k == "exe" ||
+			(rec["type"] == "EXECVE" && execveArgRe.MatchString(k)) {
+			if decoded, err := hex.DecodeString(v); err == nil && len(decoded) > 0 {
+				rec[k] = strings.TrimSpace(strings.ReplaceAll(string(decoded), "\x00", " "))
+			}
+		}
+	}
+	return rec
+}
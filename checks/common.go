@@ -0,0 +1,90 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/keepsea/goDetect/filehash"
+	"github.com/keepsea/goDetect/rules"
+	"github.com/keepsea/goDetect/types"
+)
+
+// partialOutput 在命令失败或被中止时，附加其已产生的部分输出，便于超时后审计
+func partialOutput(out string) string {
+	if strings.TrimSpace(out) == "" {
+		return ""
+	}
+	return "\n\n--- 已收集的部分输出 ---\n" + out
+}
+
+// degradeOnFailures 在部分数据源读取失败时，将未发现异常的结果降级为"不完整"
+func degradeOnFailures(cr *types.CheckResult, failedSources []string) {
+	if len(failedSources) == 0 {
+		return
+	}
+	note := fmt.Sprintf("部分数据源读取失败: %s", strings.Join(failedSources, ", "))
+	cr.Details = note + "\n\n" + cr.Details
+	if cr.Status == types.StatusOK {
+		cr.Status = types.StatusDegraded
+		cr.Result += " (" + note + ")"
+	}
+}
+
+// offlineSkipped 返回依赖运行中系统的检查项在离线模式下的跳过结果
+func offlineSkipped(cr types.CheckResult, root string) []types.CheckResult {
+	cr.Status, cr.Result = types.StatusSkipped, "离线模式下不适用"
+	cr.Details = fmt.Sprintf("正在分析位于 '%s' 的离线文件系统，此检查项依赖运行中的系统 (进程、网络连接或内核状态)，已跳过。", root)
+	return []types.CheckResult{cr}
+}
+
+// hashFile 计算文件的哈希，hostPath 为实际读取的路径，path 为报告中显示的路径。
+// 文件无法读取、不是普通文件或超过大小限制时返回 nil
+func hashFile(hostPath, path string, maxSize int64) *rules.FileHashes {
+	sums, err := filehash.Sum(hostPath, maxSize)
+	if err != nil {
+		return nil
+	}
+	return &rules.FileHashes{Path: path, MD5: sums.MD5, SHA1: sums.SHA1, SHA256: sums.SHA256}
+}
+
+// hashRecordFiles 计算每条记录所对应文件的哈希，使用哈希类型的 IOC 进行匹配，并将哈希附加到由该记录触发的风险发现上。
+// locate 返回实际读取的路径和报告中显示的路径，返回空字符串表示该记录没有对应的文件。
+// 未加载哈希 IOC 时只为触发了风险发现的记录计算哈希
+func hashRecordFiles(ctx context.Context, engine *rules.RuleEngine, records []rules.Record, findings []rules.Finding,
+	maxSize int64, locate func(rules.Record) (string, string)) []rules.Finding {
+	related := make(map[string][]int) // 记录的文本形式 -> 由其触发的发现在 findings 中的位置
+	for i, f := range findings {
+		if f.Record != nil {
+			related[f.Record.Line()] = append(related[f.Record.Line()], i)
+		}
+	}
+	var matched []rules.Finding
+	for _, rec := range records {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes := related[rec.Line()]
+		if !engine.HashIOCsLoaded() && len(indexes) == 0 {
+			continue
+		}
+		hostPath, path := locate(rec)
+		if hostPath == "" {
+			continue
+		}
+		h := hashFile(hostPath, path, maxSize)
+		if h == nil {
+			continue
+		}
+		for _, i := range indexes {
+			if findings[i].File == nil {
+				findings[i].File = h
+			}
+		}
+		for _, f := range engine.MatchHashes(*h) {
+			f.Record = rec
+			matched = append(matched, f)
+		}
+	}
+	return append(findings, matched...)
+}
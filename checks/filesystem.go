@@ -2,48 +2,92 @@ package checks
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/keepsea/goDetect/core"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "SuidSgidFilesCheck", Category: "filesystem", Tags: []string{"file", "rules", "baseline", "container"},
+		Fields: []string{"path", "mode", "owner", "group", "size"},
+		New: func(o core.Options) core.Checker {
+			return SuidSgidFilesCheck{RuleEngine: o.RuleEngine, Dirs: o.SuidDirs, Root: o.Root, HashMaxFileSize: o.HashMaxFileSizeMB * 1024 * 1024}
+		}})
+	core.Register(core.Registration{Name: "RecentlyModifiedFilesCheck", Category: "filesystem", Tags: []string{"file", "audit", "rules"},
+		Fields: []string{"path", "mode", "owner", "group", "size"},
+		New: func(o core.Options) core.Checker {
+			return RecentlyModifiedFilesCheck{RuleEngine: o.RuleEngine, Paths: o.MtimePaths, Days: o.MtimeDays, Root: o.Root}
+		}})
+	core.Register(core.Registration{Name: "TempDirsCheck", Category: "filesystem", Tags: []string{"file", "ioc", "container"},
+		New: func(o core.Options) core.Checker {
+			return TempDirsCheck{
+				RuleEngine:      o.RuleEngine,
+				TempDirs:        o.TempDirs,
+				Root:            o.Root,
+				YaraMaxDepth:    o.YaraMaxDepth,
+				YaraMaxFileSize: o.YaraMaxFileSizeMB * 1024 * 1024,
+				HashMaxFileSize: o.HashMaxFileSizeMB * 1024 * 1024,
+			}
+		}})
+}
+
 // --- SuidSgidFilesCheck ---
 type SuidSgidFilesCheck struct {
-	RuleEngine *rules.RuleEngine
-	Dirs       []string
+	RuleEngine      *rules.RuleEngine
+	Dirs            []string
+	Root            string
+	HashMaxFileSize int64 // 超过该大小(字节)的文件不计算哈希，0为不限制
 }
 
 func (c SuidSgidFilesCheck) Name() string { return "SuidSgidFilesCheck" }
-func (c SuidSgidFilesCheck) Execute() []types.CheckResult {
+func (c SuidSgidFilesCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "🗂️ 文件系统",
 	}
 
-	var allOutput []string
+	var allOutput, failedDirs []string
 	for _, dir := range c.Dirs {
-		out, err := utils.RunCommand("find", dir, "-type", "f", `(`, "-perm", "-4000", "-o", "-perm", "-2000", `)`, "-ls")
-		if err == nil && strings.TrimSpace(out) != "" {
+		if ctx.Err() != nil {
+			break
+		}
+		out, err := utils.RunCommand(ctx, "find", utils.HostPath(c.Root, dir), "-type", "f", `(`, "-perm", "-4000", "-o", "-perm", "-2000", `)`, "-ls")
+		out = utils.StripRoot(c.Root, out)
+		if err != nil && ctx.Err() == nil {
+			failedDirs = append(failedDirs, dir)
+		}
+		if strings.TrimSpace(out) != "" {
 			allOutput = append(allOutput, fmt.Sprintf("--- 在目录 '%s' 中的扫描结果 ---\n%s", dir, out))
 		}
 	}
 
 	if len(allOutput) == 0 {
-		cr.IsSuspicious, cr.Result, cr.Details = false, "在指定目录中未发现SUID/SGID文件", "扫描目录: "+strings.Join(c.Dirs, ", ")
+		cr.Status, cr.Result, cr.Details = types.StatusOK, "在指定目录中未发现SUID/SGID文件", "扫描目录: "+strings.Join(c.Dirs, ", ")
+		degradeOnFailures(&cr, failedDirs)
 		return []types.CheckResult{cr}
 	}
 
 	cr.Details = strings.Join(allOutput, "\n\n")
-	findings := c.RuleEngine.Match("SuidSgidFilesCheck", cr.Details)
+	cr.Artifacts = parseFindLsArtifacts(cr.Details)
+	records := findLsRecords(cr.Details)
+	findings := c.RuleEngine.MatchRecords("SuidSgidFilesCheck", records)
+	findings = hashRecordFiles(ctx, c.RuleEngine, records, findings, c.HashMaxFileSize, func(rec rules.Record) (string, string) {
+		return locateRegularFile(c.Root, rec)
+	})
 	cr.Findings = findings
 
 	if len(findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个可疑的SUID/SGID文件", len(findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个可疑的SUID/SGID文件", len(findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现可疑的SUID/SGID文件"
+		cr.Status, cr.Result = types.StatusOK, "未发现可疑的SUID/SGID文件"
 	}
+	degradeOnFailures(&cr, failedDirs)
 	return []types.CheckResult{cr}
 }
 
@@ -52,68 +96,174 @@ type RecentlyModifiedFilesCheck struct {
 	RuleEngine *rules.RuleEngine
 	Paths      []string // ** FIXED **: Changed from Path to Paths
 	Days       int
+	Root       string
 }
 
-func (c RecentlyModifiedFilesCheck) Name() string {
-	return fmt.Sprintf("RecentlyModifiedFilesCheck", strings.Join(c.Paths, ","), c.Days)
-}
-func (c RecentlyModifiedFilesCheck) Execute() []types.CheckResult {
+func (c RecentlyModifiedFilesCheck) Name() string { return "RecentlyModifiedFilesCheck" }
+func (c RecentlyModifiedFilesCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
-		Category: "🗂️ 文件系统",
+		Category:    "🗂️ 文件系统",
+		Description: fmt.Sprintf("检查 %s 目录下过去%d天的修改", strings.Join(c.Paths, ","), c.Days),
 	}
 
-	var allOutput []string
+	var allOutput, failedPaths []string
 	for _, path := range c.Paths {
-		out, err := utils.RunCommand("find", path, "-type", "f", "-mtime", fmt.Sprintf("-%d", c.Days), "-ls")
-		if err == nil && strings.TrimSpace(out) != "" {
+		if ctx.Err() != nil {
+			break
+		}
+		out, err := utils.RunCommand(ctx, "find", utils.HostPath(c.Root, path), "-type", "f", "-mtime", fmt.Sprintf("-%d", c.Days), "-ls")
+		out = utils.StripRoot(c.Root, out)
+		if err != nil && ctx.Err() == nil {
+			failedPaths = append(failedPaths, path)
+		}
+		if strings.TrimSpace(out) != "" {
 			allOutput = append(allOutput, fmt.Sprintf("--- 在路径 '%s' 中的扫描结果 ---\n%s", path, out))
 		}
 	}
 
 	if len(allOutput) == 0 {
-		cr.IsSuspicious, cr.Result, cr.Details = false, "在指定路径中未发现近期修改的文件", "扫描路径: "+strings.Join(c.Paths, ", ")
+		cr.Status, cr.Result, cr.Details = types.StatusOK, "在指定路径中未发现近期修改的文件", "扫描路径: "+strings.Join(c.Paths, ", ")
+		degradeOnFailures(&cr, failedPaths)
 		return []types.CheckResult{cr}
 	}
 
-	cr.IsSuspicious, cr.Result, cr.Details = false, "提取文件列表供审计", strings.Join(allOutput, "\n\n")
+	cr.Details = strings.Join(allOutput, "\n\n")
+	cr.Findings = c.RuleEngine.MatchRecords("RecentlyModifiedFilesCheck", findLsRecords(cr.Details))
+	if len(cr.Findings) > 0 {
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个可疑的近期修改文件", len(cr.Findings))
+	} else {
+		cr.Status, cr.Result = types.StatusOK, "提取文件列表供审计"
+	}
+	degradeOnFailures(&cr, failedPaths)
 	return []types.CheckResult{cr}
 }
 
 // --- TempDirsCheck ---
 type TempDirsCheck struct {
-	RuleEngine *rules.RuleEngine
-	TempDirs   []string
+	RuleEngine      *rules.RuleEngine
+	TempDirs        []string
+	Root            string
+	YaraMaxDepth    int   // YARA扫描的最大递归深度，0为不扫描
+	YaraMaxFileSize int64 // 超过该大小(字节)的文件不进行YARA扫描，0为不限制
+	HashMaxFileSize int64 // 超过该大小(字节)的文件不计算哈希，0为不限制
 }
 
 func (c TempDirsCheck) Name() string { return "TempDirsCheck" }
-func (c TempDirsCheck) Execute() []types.CheckResult {
+func (c TempDirsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "🗂️ 文件系统",
 	}
-	findArgs := append([]string{}, c.TempDirs...)
+	var findArgs []string
+	for _, dir := range c.TempDirs {
+		findArgs = append(findArgs, utils.HostPath(c.Root, dir))
+	}
 	findArgs = append(findArgs, "-ls")
-	out, err := utils.RunCommand("find", findArgs...)
-	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法执行 'find' 命令: "+err.Error()
+	out, err := utils.RunCommand(ctx, "find", findArgs...)
+	out = utils.StripRoot(c.Root, out)
+	if err != nil && strings.TrimSpace(out) == "" {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法执行 'find' 命令: "+err.Error()
 		return []types.CheckResult{cr}
 	}
 	cr.Details = "--- 临时目录文件列表 ---\n" + out
 
-	scanner := bufio.NewScanner(strings.NewReader(out))
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) > 0 {
-			fileName := fields[len(fields)-1]
-			findings := c.RuleEngine.MatchIOC("filename", fileName)
-			cr.Findings = append(cr.Findings, findings...)
+	records := findLsRecords(out)
+	for _, rec := range records {
+		findings := c.RuleEngine.MatchIOC("filename", rec["path"])
+		for i := range findings {
+			findings[i].Record = rec
 		}
+		cr.Findings = append(cr.Findings, findings...)
+	}
+	cr.Findings = hashRecordFiles(ctx, c.RuleEngine, records, cr.Findings, c.HashMaxFileSize, func(rec rules.Record) (string, string) {
+		return locateRegularFile(c.Root, rec)
+	})
+
+	if rules.YaraEnabled && c.YaraMaxDepth > 0 {
+		cr.Findings = append(cr.Findings, c.scanWithYara(ctx)...)
 	}
 
 	if len(cr.Findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("在临时目录中发现 %d 个可疑文件", len(cr.Findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("在临时目录中发现 %d 个可疑文件", len(cr.Findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未在临时目录中发现已知可疑文件"
+		cr.Status, cr.Result = types.StatusOK, "未在临时目录中发现已知可疑文件"
+	}
+	if err != nil {
+		degradeOnFailures(&cr, c.TempDirs)
 	}
 	return []types.CheckResult{cr}
 }
+
+// scanWithYara 按深度限制遍历临时目录，使用YARA规则扫描其中的普通文件
+func (c TempDirsCheck) scanWithYara(ctx context.Context) []rules.Finding {
+	var findings []rules.Finding
+	for _, dir := range c.TempDirs {
+		root := filepath.Clean(utils.HostPath(c.Root, dir))
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				return nil
+			}
+			depth := 0
+			if rel, err := filepath.Rel(root, path); err == nil && rel != "." {
+				depth = len(strings.Split(rel, string(filepath.Separator)))
+			}
+			if info.IsDir() {
+				if depth >= c.YaraMaxDepth {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.Mode().IsRegular() || (c.YaraMaxFileSize > 0 && info.Size() > c.YaraMaxFileSize) {
+				return nil
+			}
+			matched := c.RuleEngine.ScanFileWithYara(path)
+			if len(matched) > 0 {
+				if h := hashFile(path, utils.StripRoot(c.Root, path), c.HashMaxFileSize); h != nil {
+					rules.AttachFile(matched, h)
+				}
+			}
+			findings = append(findings, matched...)
+			return nil
+		})
+	}
+	return findings
+}
+
+// parseFindLsArtifacts 从 `find -ls` 的输出中提取文件路径及其权限、属主和大小
+func parseFindLsArtifacts(out string) []types.Artifact {
+	var artifacts []types.Artifact
+	for _, rec := range findLsRecords(out) {
+		artifacts = append(artifacts, types.Artifact{
+			Key:   rec["path"],
+			Value: fmt.Sprintf("%s %s:%s size=%s", rec["mode"], rec["owner"], rec["group"], rec["size"]),
+		})
+	}
+	return artifacts
+}
+
+// locateRegularFile 返回 `find -ls` 记录中普通文件在目标文件系统中的实际路径和显示路径，其他类型的文件返回空字符串
+func locateRegularFile(root string, rec rules.Record) (string, string) {
+	if !strings.HasPrefix(rec["mode"], "-") {
+		return "", ""
+	}
+	return utils.HostPath(root, rec["path"]), rec["path"]
+}
+
+// findLsRecords 将 `find -ls` 的输出转换为供规则匹配的记录，字段包括 path、mode、owner、group 和 size。
+// 分节标题等不符合格式的行会被忽略
+func findLsRecords(out string) []rules.Record {
+	var records []rules.Record
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		// 格式: inode blocks perms links owner group size month day time|year path
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 11 || strings.HasPrefix(fields[0], "---") {
+			continue
+		}
+		records = append(records, rules.NewRecord(strings.Join(fields, " "),
+			"path", strings.Join(fields[10:], " "), "mode", fields[2], "owner", fields[4], "group", fields[5], "size", fields[6]))
+	}
+	return records
+}
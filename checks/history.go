@@ -2,38 +2,52 @@ package checks
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/keepsea/goDetect/core"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
+	"github.com/keepsea/goDetect/utils"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "HistoryCheck", Category: "history", Tags: []string{"file", "ioc", "container"},
+		New: func(o core.Options) core.Checker {
+			return HistoryCheck{RuleEngine: o.RuleEngine, Filenames: o.HistoryFilenames, Root: o.Root}
+		}})
+}
+
 // HistoryCheck 检查所有用户的命令历史
 type HistoryCheck struct {
 	RuleEngine *rules.RuleEngine
 	Filenames  []string
+	Root       string
 }
 
 func (c HistoryCheck) Name() string { return "HistoryCheck" }
-func (c HistoryCheck) Execute() []types.CheckResult {
+func (c HistoryCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "📝 命令历史",
 	}
 
 	var contentBuilder strings.Builder
-	passwdFile, err := os.Open("/etc/passwd")
+	passwdFile, err := os.Open(utils.HostPath(c.Root, "/etc/passwd"))
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法打开 /etc/passwd 文件: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法打开 /etc/passwd 文件: "+err.Error()
 		return []types.CheckResult{cr}
 	}
 	defer passwdFile.Close()
 
 	scanner := bufio.NewScanner(passwdFile)
 	for scanner.Scan() {
+		if ctx.Err() != nil {
+			break
+		}
 		parts := strings.Split(scanner.Text(), ":")
 		if len(parts) < 6 {
 			continue
@@ -43,10 +57,10 @@ func (c HistoryCheck) Execute() []types.CheckResult {
 		// 使用可配置的文件名列表
 		for _, hf := range c.Filenames {
 			historyPath := filepath.Join(homeDir, hf)
-			if _, err := os.Stat(historyPath); os.IsNotExist(err) {
+			if _, err := os.Stat(utils.HostPath(c.Root, historyPath)); os.IsNotExist(err) {
 				continue
 			}
-			content, err := ioutil.ReadFile(historyPath)
+			content, err := ioutil.ReadFile(utils.HostPath(c.Root, historyPath))
 			if err == nil {
 				contentBuilder.WriteString(fmt.Sprintf("\n--- 用户 '%s' (%s) ---\n%s", username, historyPath, string(content)))
 			}
@@ -58,15 +72,16 @@ func (c HistoryCheck) Execute() []types.CheckResult {
 	historyScanner := bufio.NewScanner(strings.NewReader(cr.Details))
 	for historyScanner.Scan() {
 		line := historyScanner.Text()
-		// 注意: 我们假设IOC的type是 'history_keyword'
-		findings := c.RuleEngine.MatchIOC("history_keyword", line)
-		cr.Findings = append(cr.Findings, findings...)
+		// 除 'history_keyword' 外，命令中出现的恶意域名和 URL (如从情报源导入的下载地址) 同样可疑
+		for _, iocType := range []string{"history_keyword", "domain", "url"} {
+			cr.Findings = append(cr.Findings, c.RuleEngine.MatchIOC(iocType, line)...)
+		}
 	}
 
 	if len(cr.Findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 条可疑的命令历史", len(cr.Findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 条可疑的命令历史", len(cr.Findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现可疑的命令历史"
+		cr.Status, cr.Result = types.StatusOK, "未发现可疑的命令历史"
 	}
 	return []types.CheckResult{cr}
 }
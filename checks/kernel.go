@@ -1,36 +1,248 @@
 package checks
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/keepsea/goDetect/core"
+	"github.com/keepsea/goDetect/procfs"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "KernelModulesCheck", Category: "kernel", Tags: []string{"live", "rules", "baseline"},
+		Fields: []string{"name", "size", "refcnt", "state", "taint", "deps", "source"},
+		New:    func(o core.Options) core.Checker { return KernelModulesCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+}
+
 // --- KernelModulesCheck ---
 type KernelModulesCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string // 非空时表示离线分析，此检查项将被跳过
 }
 
 func (c KernelModulesCheck) Name() string { return "KernelModulesCheck" }
-func (c KernelModulesCheck) Execute() []types.CheckResult {
+func (c KernelModulesCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "🧠 内核与模块",
 	}
-	out, err := utils.RunCommand("lsmod")
+	if c.Root != "" {
+		return offlineSkipped(cr, c.Root)
+	}
+	procMods, sysMods, err := moduleViews()
+	if os.IsNotExist(err) {
+		cr.Status, cr.Result, cr.Details = types.StatusSkipped, "内核未启用模块支持", "/proc/modules 不存在，当前内核不支持可加载模块 (CONFIG_MODULES 未开启)。"
+		return []types.CheckResult{cr}
+	}
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法执行 'lsmod' 命令: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法读取内核模块列表: "+err.Error()
 		return []types.CheckResult{cr}
 	}
-	cr.Details = "--- 'lsmod' 原始输出 ---\n" + out
-	findings := c.RuleEngine.Match("KernelModulesCheck", cr.Details)
-	cr.Findings = findings
+	var failedSources []string
+	var builder strings.Builder
+
+	// 随内核安装的模块索引，用于识别从 /lib/modules 之外加载的模块
+	release, _ := procfs.KernelRelease()
+	index, err := procfs.ModuleIndex(release)
+	if err != nil {
+		failedSources = append(failedSources, "/lib/modules/"+release+"/modules.dep")
+	}
+
+	builder.WriteString(fmt.Sprintf("--- 已加载的内核模块 (/proc/modules，共 %d 个) ---\n", len(procMods)))
+	var records []rules.Record
+	for _, m := range sortedModules(procMods) {
+		source := "-"
+		if index != nil {
+			if path, ok := index[m.Name]; ok {
+				source = path
+			} else {
+				source = "(不在 /lib/modules 中)"
+				cr.Findings = append(cr.Findings, moduleFinding("Module_Outside_Lib_Modules",
+					"模块不属于当前内核在 /lib/modules 中安装的模块，可能是通过 insmod 从其他路径加载的。", "High", m.Name))
+			}
+		}
+		line := fmt.Sprintf("%s size=%d refcnt=%d state=%s taint=%s deps=%s source=%s",
+			m.Name, m.Size, m.RefCount, m.State, orDash(m.TaintFlag), orDash(strings.Join(m.Deps, ",")), source)
+		builder.WriteString(line + "\n")
+		rec := rules.NewRecord(line,
+			"name", m.Name, "size", strconv.Itoa(m.Size), "refcnt", strconv.Itoa(m.RefCount), "state", m.State,
+			"taint", m.TaintFlag, "deps", strings.Join(m.Deps, ","), "source", source)
+		records = append(records, rec)
+		cr.Artifacts = append(cr.Artifacts, types.Artifact{Key: m.Name, Value: fmt.Sprintf("size=%d", m.Size)})
+
+		// 一个模块可能同时带有多个污染标记 (如强制加载的未签名模块)，逐个检查，每种情况各报告一次
+		if strings.Contains(m.TaintFlag, "F") {
+			f := moduleFinding("Forced_Module_Load", "模块被强制加载，绕过了内核的版本校验。", "High", line)
+			f.Record = rec
+			cr.Findings = append(cr.Findings, f)
+		}
+		var kinds []string
+		if strings.Contains(m.TaintFlag, "O") {
+			kinds = append(kinds, "树外 (O)")
+		}
+		if strings.Contains(m.TaintFlag, "E") {
+			kinds = append(kinds, "未签名 (E)")
+		}
+		if len(kinds) > 0 {
+			f := moduleFinding("Unsigned_Or_OutOfTree_Module",
+				fmt.Sprintf("模块为%s模块，需要确认其来源。", strings.Join(kinds, "且")), "Medium", line)
+			f.Record = rec
+			cr.Findings = append(cr.Findings, f)
+		}
+	}
+
+	// 交叉比对 /proc/modules 与 /sys/module。rootkit 通常只从其中一个视图中摘除自身
+	onlySys, onlyProc := diffModuleViews(procMods, sysMods)
+	for _, name := range onlySys {
+		cr.Findings = append(cr.Findings, moduleFinding("Hidden_Module_Proc",
+			"模块存在于 /sys/module 中但未出现在 /proc/modules 中，可能被 rootkit 从模块链表中隐藏。", "Critical",
+			fmt.Sprintf("%s initstate=%s refcnt=%d", name, sysMods[name].InitState, sysMods[name].RefCount)))
+	}
+	for _, name := range onlyProc {
+		cr.Findings = append(cr.Findings, moduleFinding("Hidden_Module_Sysfs",
+			"模块存在于 /proc/modules 中但未出现在 /sys/module 中，可能被 rootkit 从 sysfs 中隐藏。", "Critical", name))
+	}
+	for _, m := range sortedModules(procMods) {
+		if sm, ok := sysMods[m.Name]; ok && sm.InitState != "live" {
+			builder.WriteString(fmt.Sprintf("注意: 模块 %s 的 initstate 为 %s\n", m.Name, sm.InitState))
+		}
+	}
+
+	// lsmod 的输出同样来自 /proc/modules，两者不一致说明 lsmod 被替换或被劫持
+	out, err := utils.RunCommand(ctx, "lsmod")
+	if err != nil {
+		builder.WriteString("\nlsmod 命令不可用，跳过与 lsmod 的比对: " + err.Error() + "\n")
+	} else {
+		listed := make(map[string]bool)
+		for i, line := range strings.Split(out, "\n") {
+			if fields := strings.Fields(line); i > 0 && len(fields) > 0 {
+				listed[fields[0]] = true
+			}
+		}
+		current, _, _ := moduleViews()
+		for _, m := range sortedModules(procMods) {
+			if _, stillLoaded := current[m.Name]; stillLoaded && !listed[m.Name] {
+				cr.Findings = append(cr.Findings, moduleFinding("Hidden_Module_Lsmod",
+					"模块存在于 /proc/modules 中但未出现在 lsmod 的输出中，lsmod 可能被替换或被劫持。", "Critical", m.Name))
+			}
+		}
+	}
+
+	// 内核污染标记在模块卸载或隐藏后依然保留，无法由可见模块解释的标记值得关注
+	taint, flags, err := procfs.KernelTaint()
+	if err != nil {
+		failedSources = append(failedSources, "/proc/sys/kernel/tainted")
+	} else {
+		builder.WriteString(fmt.Sprintf("\n--- 内核污染标记 (/proc/sys/kernel/tainted = %d) ---\n", taint))
+		visibleTaint := ""
+		for _, m := range procMods {
+			visibleTaint += m.TaintFlag
+		}
+		for _, f := range flags {
+			builder.WriteString(fmt.Sprintf("%s (bit %d): %s\n", f.Letter, f.Bit, f.Description))
+			if strings.Contains("FOE", f.Letter) && !strings.Contains(visibleTaint, f.Letter) {
+				cr.Findings = append(cr.Findings, moduleFinding("Unexplained_Kernel_Taint",
+					"内核带有模块相关的污染标记，但没有任何可见模块带有该标记，相关模块可能已被卸载或隐藏。", "Medium",
+					fmt.Sprintf("%s (bit %d): %s", f.Letter, f.Bit, f.Description)))
+			}
+		}
+	}
+	cr.Details = builder.String()
 
-	if len(findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个可疑的内核模块", len(findings))
+	cr.Findings = append(cr.Findings, c.RuleEngine.MatchRecords("KernelModulesCheck", records)...)
+
+	if len(cr.Findings) > 0 {
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 处内核模块异常", len(cr.Findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现可疑的内核模块"
+		cr.Status, cr.Result = types.StatusOK, "未发现可疑的内核模块"
 	}
+	degradeOnFailures(&cr, failedSources)
 	return []types.CheckResult{cr}
 }
+
+// moduleViews 读取 /proc/modules 和 /sys/module 两个模块视图
+func moduleViews() (map[string]procfs.Module, map[string]procfs.SysModule, error) {
+	modules, err := procfs.Modules()
+	if err != nil {
+		return nil, nil, err
+	}
+	procMods := make(map[string]procfs.Module, len(modules))
+	for _, m := range modules {
+		procMods[m.Name] = m
+	}
+	sysModules, err := procfs.SysModules()
+	if err != nil {
+		return nil, nil, err
+	}
+	sysMods := make(map[string]procfs.SysModule, len(sysModules))
+	for _, m := range sysModules {
+		sysMods[m.Name] = m
+	}
+	return procMods, sysMods, nil
+}
+
+// diffModuleViews 返回只出现在其中一个视图中的模块。差异会经过一次复核，以排除比对期间正在加载或卸载的模块
+func diffModuleViews(procMods map[string]procfs.Module, sysMods map[string]procfs.SysModule) (onlySys, onlyProc []string) {
+	diff := func(p map[string]procfs.Module, s map[string]procfs.SysModule) (map[string]bool, map[string]bool) {
+		inSys, inProc := make(map[string]bool), make(map[string]bool)
+		for name := range s {
+			if _, ok := p[name]; !ok {
+				inSys[name] = true
+			}
+		}
+		for name := range p {
+			if _, ok := s[name]; !ok {
+				inProc[name] = true
+			}
+		}
+		return inSys, inProc
+	}
+	firstSys, firstProc := diff(procMods, sysMods)
+	if len(firstSys) == 0 && len(firstProc) == 0 {
+		return nil, nil
+	}
+	p2, s2, err := moduleViews()
+	if err != nil {
+		return nil, nil
+	}
+	secondSys, secondProc := diff(p2, s2)
+	for name := range firstSys {
+		if secondSys[name] {
+			onlySys = append(onlySys, name)
+		}
+	}
+	for name := range firstProc {
+		if secondProc[name] {
+			onlyProc = append(onlyProc, name)
+		}
+	}
+	sort.Strings(onlySys)
+	sort.Strings(onlyProc)
+	return onlySys, onlyProc
+}
+
+func sortedModules(modules map[string]procfs.Module) []procfs.Module {
+	var sorted []procfs.Module
+	for _, m := range modules {
+		sorted = append(sorted, m)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+func moduleFinding(name, description, riskLevel, line string) rules.Finding {
+	return rules.Finding{
+		Source:      "CrossView",
+		Name:        name,
+		Description: description,
+		RiskLevel:   riskLevel,
+		MatchedLine: line,
+		Attack:      &rules.Attack{Tactics: []string{"persistence", "privilege-escalation"}, Techniques: []string{"T1547.006"}}, // 内核模块与扩展
+	}
+}
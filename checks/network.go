@@ -1,103 +1,172 @@
 package checks
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/keepsea/goDetect/core"
+	"github.com/keepsea/goDetect/procfs"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "ListeningPortsCheck", Category: "network", Tags: []string{"live", "rules", "baseline"},
+		Fields: []string{"proto", "state", "local_ip", "local_port", "uid", "pid", "process", "exe"},
+		New:    func(o core.Options) core.Checker { return ListeningPortsCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+	core.Register(core.Registration{Name: "EstablishedConnectionsCheck", Category: "network", Tags: []string{"live", "ioc", "rules"},
+		Fields: []string{"proto", "state", "local_ip", "local_port", "remote_ip", "remote_port", "uid", "pid", "process", "exe"},
+		New: func(o core.Options) core.Checker {
+			return EstablishedConnectionsCheck{RuleEngine: o.RuleEngine, Root: o.Root}
+		}})
+	core.Register(core.Registration{Name: "PromiscuousModeCheck", Category: "network", Tags: []string{"live"},
+		New: func(o core.Options) core.Checker { return PromiscuousModeCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+}
+
 // --- ListeningPortsCheck ---
 type ListeningPortsCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string // 非空时表示离线分析，此检查项将被跳过
 }
 
 func (c ListeningPortsCheck) Name() string { return "ListeningPortsCheck" }
-func (c ListeningPortsCheck) Execute() []types.CheckResult {
+func (c ListeningPortsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "🔌 网络连接",
 	}
-	out, err := utils.RunCommand("ss", "-lntup")
-	if err != nil {
-		out, err = utils.RunCommand("netstat", "-lntup")
-		if err != nil {
-			cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法执行 'ss' 和 'netstat' 命令: "+err.Error()
-			return []types.CheckResult{cr}
+	if c.Root != "" {
+		return offlineSkipped(cr, c.Root)
+	}
+	sockets, failed, err := procfs.Sockets(ctx)
+	if err != nil && len(sockets) == 0 {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法读取套接字表: "+err.Error()
+		return []types.CheckResult{cr}
+	}
+	var lines []string
+	var records []rules.Record
+	for _, s := range sockets {
+		if !s.Listening() {
+			continue
 		}
+		rec := socketRecord(s, false)
+		lines = append(lines, rec.Line())
+		records = append(records, rec)
+		cr.Artifacts = append(cr.Artifacts, types.Artifact{Key: strings.TrimSuffix(s.Proto, "6") + " " + s.Local(), Value: s.Process})
 	}
-	cr.Details = "--- 原始输出 ---\n" + out
-	findings := c.RuleEngine.Match("ListeningPortsCheck", cr.Details)
+	cr.Details = fmt.Sprintf("--- 监听中的套接字 (读取自 /proc/net，共 %d 个) ---\n", len(lines)) + strings.Join(lines, "\n")
+	findings := c.RuleEngine.MatchRecords("ListeningPortsCheck", records)
 	cr.Findings = findings
 
 	if len(findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个可疑的监听端口", len(findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个可疑的监听端口", len(findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现可疑监听端口"
+		cr.Status, cr.Result = types.StatusOK, "未发现可疑监听端口"
 	}
+	degradeOnFailures(&cr, failed)
 	return []types.CheckResult{cr}
 }
 
 // --- EstablishedConnectionsCheck ---
 type EstablishedConnectionsCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string // 非空时表示离线分析，此检查项将被跳过
 }
 
 func (c EstablishedConnectionsCheck) Name() string { return "EstablishedConnectionsCheck" }
-func (c EstablishedConnectionsCheck) Execute() []types.CheckResult {
+func (c EstablishedConnectionsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "🔌 网络连接",
 	}
-	out, err := utils.RunCommand("ss", "-ntp")
-	if err != nil {
-		out, _ = utils.RunCommand("netstat", "-ntp")
+	if c.Root != "" {
+		return offlineSkipped(cr, c.Root)
+	}
+	sockets, failed, err := procfs.Sockets(ctx)
+	if err != nil && len(sockets) == 0 {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法读取套接字表: "+err.Error()
+		return []types.CheckResult{cr}
 	}
-	cr.Details = "--- 原始输出 ---\n" + out
+	var lines []string
+	var records []rules.Record
+	for _, s := range sockets {
+		if s.Listening() || s.State == "CLOSE" {
+			continue
+		}
+		rec := socketRecord(s, true)
+		lines = append(lines, rec.Line())
+		records = append(records, rec)
 
-	// 使用IOC进行IP匹配
-	re := regexp.MustCompile(`(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}):\d+`)
-	scanner := bufio.NewScanner(strings.NewReader(out))
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := re.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			ip := matches[1]
-			findings := c.RuleEngine.MatchIOC("ip", ip)
-			cr.Findings = append(cr.Findings, findings...)
+		// 使用IOC对远端IP进行匹配
+		findings := c.RuleEngine.MatchIOC("ip", s.RemoteAddr.Unmap().String())
+		for i := range findings {
+			findings[i].MatchedLine += " (连接: " + rec.Line() + ")"
+			findings[i].Record = rec
 		}
+		cr.Findings = append(cr.Findings, findings...)
 	}
+	cr.Details = fmt.Sprintf("--- 网络连接 (读取自 /proc/net，共 %d 个) ---\n", len(lines)) + strings.Join(lines, "\n")
+	cr.Findings = append(cr.Findings, c.RuleEngine.MatchRecords("EstablishedConnectionsCheck", records)...)
 
 	if len(cr.Findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个与可疑IP建立的连接", len(cr.Findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个可疑连接", len(cr.Findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现与已知可疑IP的连接"
+		cr.Status, cr.Result = types.StatusOK, "未发现与已知可疑IP的连接或可疑连接模式"
 	}
+	degradeOnFailures(&cr, failed)
 	return []types.CheckResult{cr}
 }
 
 // --- PromiscuousModeCheck ---
 type PromiscuousModeCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string // 非空时表示离线分析，此检查项将被跳过
 }
 
 func (c PromiscuousModeCheck) Name() string { return "PromiscuousModeCheck" }
-func (c PromiscuousModeCheck) Execute() []types.CheckResult {
+func (c PromiscuousModeCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "🔌 网络连接",
 	}
-	out, err := utils.RunCommand("ip", "link")
+	if c.Root != "" {
+		return offlineSkipped(cr, c.Root)
+	}
+	out, err := utils.RunCommand(ctx, "ip", "link")
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法执行 'ip link' 命令: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法执行 'ip link' 命令: "+err.Error()+partialOutput(out)
 		return []types.CheckResult{cr}
 	}
 	cr.Details = "--- 'ip link' 原始输出 ---\n" + out
 	if strings.Contains(strings.ToUpper(out), "PROMISC") {
-		cr.IsSuspicious, cr.Result = true, "发现有网卡处于混杂模式"
+		cr.Status, cr.Result = types.StatusSuspicious, "发现有网卡处于混杂模式"
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现处于混杂模式的网卡"
+		cr.Status, cr.Result = types.StatusOK, "未发现处于混杂模式的网卡"
 	}
 	return []types.CheckResult{cr}
 }
+
+// socketRecord 将套接字转换为供规则匹配的记录，字段包括 proto、state、local_ip、local_port、uid、pid、process 和 exe，
+// withRemote 为 true 时还包括 remote_ip 和 remote_port。IPv4 映射的 IPv6 地址按 IPv4 形式输出
+func socketRecord(s procfs.Socket, withRemote bool) rules.Record {
+	addr := s.Local()
+	if withRemote {
+		addr += " -> " + s.Remote()
+	}
+	var line string
+	if s.PID == 0 {
+		line = fmt.Sprintf("%s %s %s uid=%d pid=- (无法关联到进程)", s.Proto, s.State, addr, s.UID)
+	} else {
+		line = fmt.Sprintf("%s %s %s uid=%d pid=%d process=%s exe=%s", s.Proto, s.State, addr, s.UID, s.PID, s.Process, s.Exe)
+	}
+	rec := rules.NewRecord(line,
+		"proto", s.Proto, "state", s.State, "uid", strconv.Itoa(s.UID),
+		"local_ip", s.LocalAddr.Unmap().String(), "local_port", strconv.Itoa(int(s.LocalPort)))
+	if withRemote {
+		rec["remote_ip"], rec["remote_port"] = s.RemoteAddr.Unmap().String(), strconv.Itoa(int(s.RemotePort))
+	}
+	if s.PID != 0 {
+		rec["pid"], rec["process"], rec["exe"] = strconv.Itoa(s.PID), s.Process, s.Exe
+	}
+	return rec
+}
@@ -2,75 +2,229 @@ package checks
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/keepsea/goDetect/core"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "CronJobsCheck", Category: "persistence", Tags: []string{"file", "rules", "baseline", "container"},
+		Fields: []string{"source", "schedule", "user", "command"},
+		New:    func(o core.Options) core.Checker { return CronJobsCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+	core.Register(core.Registration{Name: "SystemdTimersCheck", Category: "persistence", Tags: []string{"file", "audit", "baseline"},
+		New: func(o core.Options) core.Checker { return SystemdTimersCheck{RuleEngine: o.RuleEngine, Root: o.Root} }})
+}
+
 // --- CronJobsCheck ---
 type CronJobsCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string
 }
 
 func (c CronJobsCheck) Name() string { return "CronJobsCheck" }
-func (c CronJobsCheck) Execute() []types.CheckResult {
+func (c CronJobsCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "⏰ 持久化机制",
 	}
 	var contentBuilder strings.Builder
-	sysCron, _ := os.ReadFile("/etc/crontab")
+	var failedFiles []string
+	var records []rules.Record
+	sysCron, err := os.ReadFile(utils.HostPath(c.Root, "/etc/crontab"))
+	if err != nil && !os.IsNotExist(err) {
+		failedFiles = append(failedFiles, "/etc/crontab")
+	}
 	contentBuilder.WriteString("--- /etc/crontab ---\n" + string(sysCron) + "\n\n")
-	files, _ := os.ReadDir("/etc/cron.d")
+	cr.Artifacts = append(cr.Artifacts, cronArtifacts("/etc/crontab", string(sysCron))...)
+	records = append(records, cronRecords("/etc/crontab", "", string(sysCron))...)
+	files, err := os.ReadDir(utils.HostPath(c.Root, "/etc/cron.d"))
+	if err != nil && !os.IsNotExist(err) {
+		failedFiles = append(failedFiles, "/etc/cron.d")
+	}
 	for _, f := range files {
-		content, _ := os.ReadFile("/etc/cron.d/" + f.Name())
+		content, err := os.ReadFile(utils.HostPath(c.Root, "/etc/cron.d/"+f.Name()))
+		if err != nil {
+			failedFiles = append(failedFiles, "/etc/cron.d/"+f.Name())
+		}
 		contentBuilder.WriteString(fmt.Sprintf("--- /etc/cron.d/%s ---\n%s\n\n", f.Name(), string(content)))
+		cr.Artifacts = append(cr.Artifacts, cronArtifacts("/etc/cron.d/"+f.Name(), string(content))...)
+		records = append(records, cronRecords("/etc/cron.d/"+f.Name(), "", string(content))...)
 	}
-	passwdFile, err := os.Open("/etc/passwd")
-	if err == nil {
+	passwdFile, err := os.Open(utils.HostPath(c.Root, "/etc/passwd"))
+	if err != nil {
+		failedFiles = append(failedFiles, "/etc/passwd")
+	} else {
 		defer passwdFile.Close()
 		scanner := bufio.NewScanner(passwdFile)
 		for scanner.Scan() {
 			parts := strings.Split(scanner.Text(), ":")
+			if ctx.Err() != nil {
+				break
+			}
 			if len(parts) > 0 {
 				username := parts[0]
-				userCron, err := utils.RunCommand("crontab", "-u", username, "-l")
+				userCron, err := c.userCrontab(ctx, username)
 				if err == nil && strings.TrimSpace(userCron) != "" {
 					contentBuilder.WriteString(fmt.Sprintf("--- 用户 '%s' 的 Cron ---\n%s\n\n", username, userCron))
+					cr.Artifacts = append(cr.Artifacts, cronArtifacts("user:"+username, userCron)...)
+					records = append(records, cronRecords("user:"+username, username, userCron)...)
 				}
 			}
 		}
 	}
 	cr.Details = contentBuilder.String()
-	findings := c.RuleEngine.Match("CronJobsCheck", cr.Details)
+	findings := c.RuleEngine.MatchRecords("CronJobsCheck", records)
 	cr.Findings = findings
 
 	if len(findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 条可疑的定时任务", len(findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 条可疑的定时任务", len(findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现可疑模式的定时任务"
+		cr.Status, cr.Result = types.StatusOK, "未发现可疑模式的定时任务"
 	}
+	degradeOnFailures(&cr, failedFiles)
 	return []types.CheckResult{cr}
 }
 
 // --- SystemdTimersCheck ---
 type SystemdTimersCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string
 }
 
 func (c SystemdTimersCheck) Name() string { return "SystemdTimersCheck" }
-func (c SystemdTimersCheck) Execute() []types.CheckResult {
+func (c SystemdTimersCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "⏰ 持久化机制",
 	}
-	out, err := utils.RunCommand("systemctl", "list-timers", "--all")
+	if c.Root != "" {
+		return c.executeOffline(cr)
+	}
+	out, err := utils.RunCommand(ctx, "systemctl", "list-timers", "--all")
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败或系统未使用 Systemd", "无法执行 'systemctl list-timers': "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败或系统未使用 Systemd", "无法执行 'systemctl list-timers': "+err.Error()+partialOutput(out)
 	} else {
-		cr.IsSuspicious, cr.Result, cr.Details = false, "提取所有 Systemd Timers 供审计", "--- 原始输出 ---\n"+out
+		cr.Status, cr.Result, cr.Details = types.StatusOK, "提取所有 Systemd Timers 供审计", "--- 原始输出 ---\n"+out
+		cr.Artifacts = parseTimerArtifacts(out)
 	}
 	return []types.CheckResult{cr}
 }
+
+// userCrontabDirs 是各发行版存放用户 crontab 的目录 (Debian 系与 RHEL 系)
+var userCrontabDirs = []string{"/var/spool/cron/crontabs", "/var/spool/cron"}
+
+// userCrontab 获取用户的 crontab。离线模式下 crontab 命令只能读取当前系统，因此直接读取镜像中的 spool 文件
+func (c CronJobsCheck) userCrontab(ctx context.Context, username string) (string, error) {
+	if c.Root == "" {
+		return utils.RunCommand(ctx, "crontab", "-u", username, "-l")
+	}
+	for _, dir := range userCrontabDirs {
+		content, err := os.ReadFile(utils.HostPath(c.Root, filepath.Join(dir, username)))
+		if err == nil {
+			return string(content), nil
+		}
+	}
+	return "", fmt.Errorf("用户 '%s' 没有 crontab", username)
+}
+
+// systemdUnitDirs 是 systemd 单元文件的标准目录
+var systemdUnitDirs = []string{"/etc/systemd/system", "/usr/lib/systemd/system", "/lib/systemd/system"}
+
+// executeOffline 在离线模式下直接解析镜像中的 .timer 单元文件，而不是调用 systemctl
+func (c SystemdTimersCheck) executeOffline(cr types.CheckResult) []types.CheckResult {
+	var contentBuilder strings.Builder
+	seen := make(map[string]bool)
+	for _, dir := range systemdUnitDirs {
+		files, err := filepath.Glob(filepath.Join(utils.HostPath(c.Root, dir), "*.timer"))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			timer := filepath.Base(file)
+			content, err := os.ReadFile(file)
+			// /etc 下的单元会覆盖 /usr/lib 下的同名单元，按目录优先级只取第一个
+			if err != nil || seen[timer] {
+				continue
+			}
+			seen[timer] = true
+			activates := strings.TrimSuffix(timer, ".timer") + ".service"
+			for _, line := range strings.Split(string(content), "\n") {
+				if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok && strings.TrimSpace(key) == "Unit" {
+					activates = strings.TrimSpace(value)
+				}
+			}
+			cr.Artifacts = append(cr.Artifacts, types.Artifact{Key: timer, Value: activates})
+			contentBuilder.WriteString(fmt.Sprintf("--- %s (%s -> %s) ---\n%s\n", filepath.Join(dir, timer), timer, activates, string(content)))
+		}
+	}
+	if len(seen) == 0 {
+		cr.Status, cr.Result, cr.Details = types.StatusOK, "未发现 Systemd Timer 单元文件", "扫描目录: "+strings.Join(systemdUnitDirs, ", ")
+		return []types.CheckResult{cr}
+	}
+	cr.Status, cr.Result, cr.Details = types.StatusOK, fmt.Sprintf("提取 %d 个 Systemd Timer 单元文件供审计", len(seen)), contentBuilder.String()
+	return []types.CheckResult{cr}
+}
+
+// cronArtifacts 将 crontab 内容中的有效条目(非空、非注释)转换为基线数据
+func cronArtifacts(source, content string) []types.Artifact {
+	var artifacts []types.Artifact
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		artifacts = append(artifacts, types.Artifact{Key: source + ": " + line})
+	}
+	return artifacts
+}
+
+// cronRecords 将 crontab 中的有效条目转换为供规则匹配的记录，字段包括 source、schedule、user 和 command。
+// owner 为空表示系统 crontab，其条目在时间字段之后带有执行用户；变量赋值行只包含 source 字段
+func cronRecords(source, owner, content string) []rules.Record {
+	var records []rules.Record
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rec := rules.NewRecord(line, "source", source)
+		fields := strings.Fields(line)
+		scheduleLen := 5
+		if strings.HasPrefix(fields[0], "@") {
+			scheduleLen = 1
+		}
+		commandStart := scheduleLen
+		if owner == "" {
+			commandStart++ // 系统 crontab 的用户字段
+		}
+		if len(fields) > commandStart && !strings.Contains(fields[0], "=") {
+			rec["schedule"] = strings.Join(fields[:scheduleLen], " ")
+			rec["user"] = owner
+			if owner == "" {
+				rec["user"] = fields[scheduleLen]
+			}
+			rec["command"] = strings.Join(fields[commandStart:], " ")
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// parseTimerArtifacts 从 `systemctl list-timers --all` 的输出中提取定时器及其触发的单元。
+// 时间列包含空格且可能为 "n/a"，因此从行尾取最后两列 UNIT 和 ACTIVATES
+func parseTimerArtifacts(out string) []types.Artifact {
+	var artifacts []types.Artifact
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasSuffix(fields[len(fields)-2], ".timer") {
+			continue
+		}
+		artifacts = append(artifacts, types.Artifact{Key: fields[len(fields)-2], Value: fields[len(fields)-1]})
+	}
+	return artifacts
+}
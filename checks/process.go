@@ -1,83 +1,334 @@
 package checks
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/keepsea/goDetect/core"
+	"github.com/keepsea/goDetect/procfs"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "SuspiciousProcessesCheck", Category: "process", Tags: []string{"live", "rules"},
+		Fields: []string{"pid", "ppid", "uid", "user", "name", "state", "start", "exe", "cwd", "cmdline", "parent_name", "parent_exe", "parent_cmdline"},
+		New: func(o core.Options) core.Checker {
+			return SuspiciousProcessesCheck{RuleEngine: o.RuleEngine, Root: o.Root, HashMaxFileSize: o.HashMaxFileSizeMB * 1024 * 1024}
+		}})
+	core.Register(core.Registration{Name: "HiddenProcessesCheck", Category: "process", Tags: []string{"live", "slow"},
+		New: func(o core.Options) core.Checker { return HiddenProcessesCheck{Root: o.Root} }})
+	core.Register(core.Registration{Name: "DeletedRunningProcessesCheck", Category: "process", Tags: []string{"live"},
+		New: func(o core.Options) core.Checker {
+			return DeletedRunningProcessesCheck{RuleEngine: o.RuleEngine, Root: o.Root}
+		}})
+}
+
 // --- SuspiciousProcessesCheck ---
 type SuspiciousProcessesCheck struct {
-	RuleEngine *rules.RuleEngine
+	RuleEngine      *rules.RuleEngine
+	Root            string // 非空时表示离线分析，此检查项将被跳过
+	HashMaxFileSize int64  // 超过该大小(字节)的可执行文件不计算哈希，0为不限制
 }
 
 func (c SuspiciousProcessesCheck) Name() string { return "SuspiciousProcessesCheck" }
-func (c SuspiciousProcessesCheck) Execute() []types.CheckResult {
+func (c SuspiciousProcessesCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "⚙️ 进程与服务",
 	}
-	out, err := utils.RunCommand("ps", "aux")
-	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败", "无法执行 'ps aux' 命令: "+err.Error()
+	if c.Root != "" {
+		return offlineSkipped(cr, c.Root)
+	}
+	// 直接读取 /proc 而不是解析 ps 的输出，避免被替换过的 ps 欺骗，也不依赖 ps 的具体实现
+	procs, err := procfs.Processes(ctx)
+	if err != nil && len(procs) == 0 {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法枚举进程: "+err.Error()
 		return []types.CheckResult{cr}
 	}
 
+	// 排除本程序及其启动的子进程 (如其他检查项正在执行的命令)
 	myPid := os.Getpid()
-	var filteredLines []string
-	lines := strings.Split(out, "\n")
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			continue
-		}
-		pid, err := strconv.Atoi(fields[1])
-		if err != nil {
+	users := userNames("")
+	byPID := make(map[int]procfs.Process, len(procs))
+	for _, p := range procs {
+		byPID[p.PID] = p
+	}
+	var lines []string
+	var records []rules.Record
+	for _, p := range procs {
+		if p.PID == myPid || p.PPID == myPid {
 			continue
 		}
-		if pid != myPid {
-			filteredLines = append(filteredLines, line)
+		rec := processRecord(p, users)
+		if parent, ok := byPID[p.PPID]; ok {
+			parentRec := processRecord(parent, users)
+			rec["parent_name"], rec["parent_exe"], rec["parent_cmdline"] = parent.Name, parentRec["exe"], parentRec["cmdline"]
 		}
+		lines = append(lines, rec.Line())
+		records = append(records, rec)
 	}
-	cr.Details = strings.Join(filteredLines, "\n")
-	findings := c.RuleEngine.Match("SuspiciousProcessesCheck", cr.Details)
+	cr.Details = fmt.Sprintf("--- 进程列表 (读取自 /proc，共 %d 个) ---\n", len(lines)) + strings.Join(lines, "\n")
+	findings := c.RuleEngine.MatchRecords("SuspiciousProcessesCheck", records)
+	// 读取 /proc/<pid>/exe 而不是 exe 路径，可执行文件已被删除或替换时得到的仍是实际运行的文件
+	findings = hashRecordFiles(ctx, c.RuleEngine, records, findings, c.HashMaxFileSize, func(rec rules.Record) (string, string) {
+		if rec["exe"] == "-" {
+			return "", ""
+		}
+		return "/proc/" + rec["pid"] + "/exe", rec["exe"]
+	})
 	cr.Findings = findings
 
 	if len(findings) > 0 {
-		cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个可疑进程", len(findings))
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个可疑进程", len(findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现可疑进程"
+		cr.Status, cr.Result = types.StatusOK, "未发现可疑进程"
 	}
 	return []types.CheckResult{cr}
 }
 
+// processRecord 将进程转换为供规则匹配的记录，字段包括 pid、ppid、uid、user、name、state、start、exe、cwd 和 cmdline
+func processRecord(p procfs.Process, users map[int]string) rules.Record {
+	user, ok := users[p.UID]
+	if !ok {
+		user = strconv.Itoa(p.UID)
+	}
+	start := "-"
+	if !p.StartTime.IsZero() {
+		start = p.StartTime.Format("2006-01-02T15:04:05")
+	}
+	cmdline := p.Cmdline
+	if cmdline == "" {
+		cmdline = "[" + p.Name + "]"
+	}
+	exe := p.Exe
+	if exe == "" {
+		exe = "-"
+	}
+	line := fmt.Sprintf("pid=%d ppid=%d user=%s state=%s start=%s exe=%s cwd=%s cmdline=%s",
+		p.PID, p.PPID, user, p.State, start, exe, p.Cwd, cmdline)
+	return rules.NewRecord(line,
+		"pid", strconv.Itoa(p.PID), "ppid", strconv.Itoa(p.PPID), "uid", strconv.Itoa(p.UID), "user", user,
+		"name", p.Name, "state", p.State, "start", start, "exe", exe, "cwd", p.Cwd, "cmdline", cmdline)
+}
+
+// userNames 读取 /etc/passwd 建立 UID 到用户名的映射，读取失败时返回空映射
+func userNames(root string) map[int]string {
+	users := make(map[int]string)
+	content, err := ioutil.ReadFile(utils.HostPath(root, "/etc/passwd"))
+	if err != nil {
+		return users
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.Split(line, ":")
+		if len(parts) < 3 {
+			continue
+		}
+		if uid, err := strconv.Atoi(parts[2]); err == nil {
+			if _, exists := users[uid]; !exists {
+				users[uid] = parts[0]
+			}
+		}
+	}
+	return users
+}
+
+// --- HiddenProcessesCheck ---
+type HiddenProcessesCheck struct {
+	Root string // 非空时表示离线分析，此检查项将被跳过
+}
+
+func (c HiddenProcessesCheck) Name() string { return "HiddenProcessesCheck" }
+func (c HiddenProcessesCheck) Execute(ctx context.Context) []types.CheckResult {
+	cr := types.CheckResult{
+		Category: "⚙️ 进程与服务",
+	}
+	if c.Root != "" {
+		return offlineSkipped(cr, c.Root)
+	}
+	listed, err := procfs.PIDs()
+	if err != nil {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", err.Error()
+		return []types.CheckResult{cr}
+	}
+	var builder strings.Builder
+	var failedSources []string
+	users := userNames("")
+	builder.WriteString(fmt.Sprintf("视图1 - 遍历 /proc 目录: %d 个进程\n", len(listed)))
+
+	// 视图2: 各进程的线程列表。线程可以通过 /proc/<tid> 直接访问，但不会出现在目录遍历中，需要与隐藏进程区分开
+	loadBefore, loadErr := procfs.LoadavgEntities()
+	threads := make(map[int]int)
+	for pid := range listed {
+		tids, _ := procfs.Threads(pid)
+		for _, tid := range tids {
+			threads[tid] = pid
+		}
+	}
+	loadAfter, _ := procfs.LoadavgEntities()
+	builder.WriteString(fmt.Sprintf("视图2 - 遍历 /proc/<pid>/task: %d 个线程\n", len(threads)))
+
+	// 视图3: 暴力探测整个PID范围，直接访问的路径无法被拦截目录遍历的 rootkit 隐藏
+	pidMax, err := procfs.PIDMax()
+	if err != nil {
+		pidMax = 4194304 // 64位内核允许的最大值
+		failedSources = append(failedSources, "/proc/sys/kernel/pid_max")
+	}
+	probed, err := procfs.ProbePIDs(ctx, pidMax)
+	builder.WriteString(fmt.Sprintf("视图3 - 探测PID 1-%d: %d 个进程或线程\n", pidMax, len(probed)))
+	candidates := make(map[int]bool)
+	for _, id := range probed {
+		if _, isThread := threads[id]; listed[id] || isThread {
+			continue
+		}
+		tgid, err := procfs.Tgid(id)
+		if err != nil || listed[tgid] {
+			continue // 已退出，或是在遍历线程列表之后新建的线程
+		}
+		candidates[tgid] = true
+	}
+	if len(candidates) > 0 {
+		// 再次遍历 /proc，排除在扫描期间新创建的进程
+		relisted, _ := procfs.PIDs()
+		for pid := range candidates {
+			if relisted[pid] {
+				continue
+			}
+			if p, err := procfs.ReadProcess(pid); err == nil {
+				cr.Findings = append(cr.Findings, hiddenProcessFinding("Hidden_Process_Kernel",
+					"进程存在但未出现在 /proc 目录遍历结果中，可能被内核级 rootkit 隐藏。", "Critical", processRecord(p, users).Line()))
+			}
+		}
+	}
+	if err != nil {
+		// 暴力探测因超时中止，交由执行器标记为超时，已得到的结果仍然保留
+		cr.Details = builder.String()
+		return []types.CheckResult{c.conclude(cr, failedSources)}
+	}
+
+	// 视图4: ps 命令的输出。ps 依赖的 libc 可能被 LD_PRELOAD 类 rootkit 劫持，而本程序直接使用系统调用
+	out, err := utils.RunCommand(ctx, "ps", "-e", "-o", "pid=")
+	if err != nil {
+		builder.WriteString("视图4 - ps 命令: 不可用 (" + err.Error() + ")\n")
+	} else {
+		psPids := make(map[int]bool)
+		for _, field := range strings.Fields(out) {
+			if pid, err := strconv.Atoi(field); err == nil {
+				psPids[pid] = true
+			}
+		}
+		builder.WriteString(fmt.Sprintf("视图4 - ps 命令: %d 个进程\n", len(psPids)))
+		myPid := os.Getpid()
+		for pid := range listed {
+			if psPids[pid] || pid == myPid {
+				continue
+			}
+			// 只有在 ps 执行前后都存在的进程才能确定被 ps 隐藏
+			if p, err := procfs.ReadProcess(pid); err == nil && p.PPID != myPid {
+				cr.Findings = append(cr.Findings, hiddenProcessFinding("Hidden_Process_Userland",
+					"进程存在于 /proc 中但未出现在 ps 的输出中，ps 可能被替换或被 LD_PRELOAD 类 rootkit 劫持。", "Critical", processRecord(p, users).Line()))
+			}
+		}
+	}
+
+	// 视图5: /proc/loadavg 中的调度实体总数。只在初始PID命名空间中比较，容器内看到的是宿主机的总数
+	if loadErr != nil {
+		failedSources = append(failedSources, "/proc/loadavg")
+	} else if p, err := procfs.ReadProcess(2); err != nil || p.Name != "kthreadd" {
+		builder.WriteString("视图5 - /proc/loadavg: 当前不在初始PID命名空间中，跳过线程总数比较\n")
+	} else {
+		total := loadBefore
+		if loadAfter < total {
+			total = loadAfter
+		}
+		builder.WriteString(fmt.Sprintf("视图5 - /proc/loadavg: %d 个调度实体\n", total))
+		tolerance := len(threads) / 20
+		if tolerance < 10 {
+			tolerance = 10
+		}
+		if total-len(threads) > tolerance {
+			cr.Findings = append(cr.Findings, hiddenProcessFinding("Thread_Count_Mismatch",
+				"内核记录的线程总数明显多于可见的线程数，可能存在被隐藏的进程。", "High",
+				fmt.Sprintf("/proc/loadavg 记录 %d 个调度实体，可见线程 %d 个，相差 %d 个", total, len(threads), total-len(threads))))
+		}
+	}
+
+	cr.Details = builder.String()
+	return []types.CheckResult{c.conclude(cr, failedSources)}
+}
+
+func (c HiddenProcessesCheck) conclude(cr types.CheckResult, failedSources []string) types.CheckResult {
+	if len(cr.Findings) > 0 {
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 处进程视图不一致", len(cr.Findings))
+	} else {
+		cr.Status, cr.Result = types.StatusOK, "各进程视图一致，未发现隐藏进程"
+	}
+	degradeOnFailures(&cr, failedSources)
+	return cr
+}
+
+func hiddenProcessFinding(name, description, riskLevel, line string) rules.Finding {
+	return rules.Finding{
+		Source:      "CrossView",
+		Name:        name,
+		Description: description,
+		RiskLevel:   riskLevel,
+		MatchedLine: line,
+		Attack:      &rules.Attack{Tactics: []string{"defense-evasion"}, Techniques: []string{"T1014"}}, // Rootkit
+	}
+}
+
 // --- DeletedRunningProcessesCheck ---
 type DeletedRunningProcessesCheck struct {
 	RuleEngine *rules.RuleEngine
+	Root       string // 非空时表示离线分析，此检查项将被跳过
 }
 
 func (c DeletedRunningProcessesCheck) Name() string {
 	return "DeletedRunningProcessesCheck"
 }
-func (c DeletedRunningProcessesCheck) Execute() []types.CheckResult {
+func (c DeletedRunningProcessesCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "⚙️ 进程与服务",
 	}
-	out, err := utils.RunCommand("lsof", "+L1")
-	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "检查失败或无权限", "无法执行 'lsof +L1': "+err.Error()
+	if c.Root != "" {
+		return offlineSkipped(cr, c.Root)
+	}
+	// 直接读取 /proc/<pid>/exe，不依赖 lsof 是否安装，也不会被替换过的 lsof 欺骗
+	procs, err := procfs.Processes(ctx)
+	if err != nil && len(procs) == 0 {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "检查失败", "无法枚举进程: "+err.Error()
 		return []types.CheckResult{cr}
 	}
-	cr.Details = "--- 'lsof +L1' 原始输出 ---\n" + out
 
-	if strings.Contains(cr.Details, "(deleted)") {
-		cr.IsSuspicious, cr.Result = true, "发现已删除但仍在运行的进程"
+	users := userNames("")
+	var lines []string
+	for _, p := range procs {
+		if !p.ExeDeleted() {
+			continue
+		}
+		rec := processRecord(p, users)
+		lines = append(lines, rec.Line())
+		cr.Findings = append(cr.Findings, rules.Finding{
+			Source:      "Check",
+			Name:        "Deleted_Executable_Running",
+			Description: "进程的可执行文件已从磁盘上删除但进程仍在运行，常见于启动后删除自身以逃避检测的恶意程序；软件升级后未重启的服务也会出现这种情况。",
+			RiskLevel:   "High",
+			MatchedLine: rec.Line(),
+			Record:      rec,
+			Attack:      &rules.Attack{Tactics: []string{"defense-evasion"}, Techniques: []string{"T1070.004"}}, // 删除文件
+		})
+	}
+	cr.Details = fmt.Sprintf("--- 可执行文件已被删除的进程 (读取自 /proc，共检查 %d 个进程) ---\n", len(procs)) + strings.Join(lines, "\n")
+
+	if len(cr.Findings) > 0 {
+		cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个可执行文件已被删除但仍在运行的进程", len(cr.Findings))
 	} else {
-		cr.IsSuspicious, cr.Result = false, "未发现已删除但仍在运行的进程"
+		cr.Status, cr.Result = types.StatusOK, "未发现已删除但仍在运行的进程"
 	}
 	return []types.CheckResult{cr}
 }
@@ -1,69 +1,122 @@
 package checks
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/keepsea/goDetect/core"
 	"github.com/keepsea/goDetect/rules"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
 )
 
+func init() {
+	core.Register(core.Registration{Name: "WebshellCheck", Category: "web", Tags: []string{"file", "slow", "container"},
+		New: func(o core.Options) core.Checker {
+			return WebshellCheck{
+				RuleEngine:      o.RuleEngine,
+				WebPath:         o.WebPath,
+				HemaPath:        o.HemaPath,
+				HemaResultPath:  o.HemaResultPath,
+				Root:            o.Root,
+				HashMaxFileSize: o.HashMaxFileSizeMB * 1024 * 1024,
+			}
+		}})
+}
+
 // --- WebshellCheck ---
 type WebshellCheck struct {
-	RuleEngine     *rules.RuleEngine
-	WebPath        string
-	HemaPath       string
-	HemaResultPath string
+	RuleEngine      *rules.RuleEngine
+	WebPath         string
+	HemaPath        string
+	HemaResultPath  string
+	Root            string // 离线分析时 WebPath 会被重定位到该目录之下
+	HashMaxFileSize int64  // 超过该大小(字节)的文件不计算哈希，0为不限制
 }
 
 func (c WebshellCheck) Name() string { return "WebshellCheck" }
-func (c WebshellCheck) Execute() []types.CheckResult {
+func (c WebshellCheck) Execute(ctx context.Context) []types.CheckResult {
 	cr := types.CheckResult{
 		Category: "🌐 Web安全",
 	}
+	if c.WebPath == "" {
+		cr.Status, cr.Result, cr.Details = types.StatusSkipped, "未提供Web目录", "未提供 -webpath 参数，已跳过 Webshell 检测。"
+		return []types.CheckResult{cr}
+	}
 	scannerPath := c.HemaPath
-	resultFilePath := c.HemaResultPath
 	if _, err := os.Stat(scannerPath); os.IsNotExist(err) {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "扫描失败", "未在当前目录下找到河马工具 'hm'。"
+		cr.Status, cr.Result, cr.Details = types.StatusError, "扫描失败", "未在当前目录下找到河马工具 'hm'。"
+		return []types.CheckResult{cr}
+	}
+	webPath := utils.HostPath(c.Root, c.WebPath)
+	if _, err := os.Stat(webPath); c.Root != "" && os.IsNotExist(err) {
+		// 扫描容器或镜像时，Web目录通常只存在于其中一部分目标中
+		cr.Status, cr.Result, cr.Details = types.StatusSkipped, "Web目录不存在", fmt.Sprintf("目标文件系统中不存在Web目录 '%s'，已跳过 Webshell 检测。", c.WebPath)
 		return []types.CheckResult{cr}
 	}
-	os.Remove(resultFilePath)
-	_, err := utils.RunCommand(scannerPath, "scan", c.WebPath, "--output", resultFilePath)
+	// 主机和各容器的 WebshellCheck 会并发执行，每次扫描都将结果写入结果目录下独立的临时目录，避免相互覆盖
+	resultDir, err := os.MkdirTemp(filepath.Dir(c.HemaResultPath), "hm-result-")
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "扫描命令执行失败", fmt.Sprintf("执行 '%s scan %s' 时发生错误: %s", scannerPath, c.WebPath, err.Error())
+		cr.Status, cr.Result, cr.Details = types.StatusError, "无法创建结果目录", "无法为河马扫描结果创建临时目录: "+err.Error()
+		return []types.CheckResult{cr}
+	}
+	defer os.RemoveAll(resultDir)
+	resultFilePath := filepath.Join(resultDir, filepath.Base(c.HemaResultPath))
+	_, err = utils.RunCommand(ctx, scannerPath, "scan", webPath, "--output", resultFilePath)
+	if err != nil {
+		cr.Status, cr.Result, cr.Details = types.StatusError, "扫描命令执行失败", fmt.Sprintf("执行 '%s scan %s' 时发生错误: %s", scannerPath, webPath, err.Error())
 		return []types.CheckResult{cr}
 	}
-	defer os.Remove(resultFilePath)
 	csvFile, err := os.Open(resultFilePath)
 	if os.IsNotExist(err) {
-		cr.IsSuspicious, cr.Result = false, "扫描完成，未发现风险文件"
+		cr.Status, cr.Result = types.StatusOK, "扫描完成，未发现风险文件"
 		return []types.CheckResult{cr}
 	}
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "无法打开结果文件", "无法打开 result.csv: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "无法打开结果文件", "无法打开 result.csv: "+err.Error()
 		return []types.CheckResult{cr}
 	}
 	defer csvFile.Close()
 	reader := csv.NewReader(csvFile)
 	records, err := reader.ReadAll()
 	if err != nil {
-		cr.IsSuspicious, cr.Result, cr.Details = true, "无法解析结果文件", "无法解析 result.csv: "+err.Error()
+		cr.Status, cr.Result, cr.Details = types.StatusError, "无法解析结果文件", "无法解析 result.csv: "+err.Error()
 		return []types.CheckResult{cr}
 	}
 	if len(records) <= 1 {
-		cr.IsSuspicious, cr.Result = false, "扫描完成，未在结果中发现风险项"
+		cr.Status, cr.Result = types.StatusOK, "扫描完成，未在结果中发现风险项"
 		return []types.CheckResult{cr}
 	}
 	var tableBuilder strings.Builder
-	tableBuilder.WriteString("| " + strings.Join(records[0], " | ") + " |\n")
-	tableBuilder.WriteString("|" + strings.Repeat(" --- |", len(records[0])) + "\n")
+	tableBuilder.WriteString("| " + strings.Join(records[0], " | ") + " | SHA256 |\n")
+	tableBuilder.WriteString("|" + strings.Repeat(" --- |", len(records[0])+1) + "\n")
 	for _, row := range records[1:] {
-		tableBuilder.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		sha256 := "-"
+		if h := c.hashCandidate(row); h != nil {
+			sha256 = h.SHA256
+			cr.Findings = append(cr.Findings, c.RuleEngine.MatchHashes(*h)...)
+		}
+		tableBuilder.WriteString("| " + utils.StripRoot(c.Root, strings.Join(row, " | ")) + " | " + sha256 + " |\n")
 	}
-	cr.IsSuspicious, cr.Result = true, fmt.Sprintf("发现 %d 个潜在风险文件", len(records)-1)
+	cr.Status, cr.Result = types.StatusSuspicious, fmt.Sprintf("发现 %d 个潜在风险文件", len(records)-1)
 	cr.Details = "以下是河马工具报告的风险文件列表：\n\n" + tableBuilder.String()
 	return []types.CheckResult{cr}
 }
+
+// hashCandidate 计算河马结果中一行所指文件的哈希。结果文件的列因版本而异，取第一个指向普通文件的绝对路径
+func (c WebshellCheck) hashCandidate(row []string) *rules.FileHashes {
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if !strings.HasPrefix(cell, "/") {
+			continue
+		}
+		if h := hashFile(cell, utils.StripRoot(c.Root, cell), c.HashMaxFileSize); h != nil {
+			return h
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 
 import (
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,24 +14,86 @@ type CheckConfig struct {
 	Explanation string `yaml:"explanation"`
 }
 
+// TimeoutConfig 定义了检查项的超时时间，0为不限制
+type TimeoutConfig struct {
+	Check  time.Duration `yaml:"check"`  // 单个检查项的超时时间
+	Global time.Duration `yaml:"global"` // 整次扫描的超时时间
+}
+
+// CheckSelectionConfig 定义了要执行或跳过的检查项
+type CheckSelectionConfig struct {
+	Checks     []string `yaml:"checks"`      // 要执行的检查项名称或标签
+	SkipChecks []string `yaml:"skip_checks"` // 要跳过的检查项名称、分类或标签
+	Categories []string `yaml:"categories"`  // 要执行的检查项分类
+}
+
+// MtimeConfig 定义了近期文件修改检查的范围
+type MtimeConfig struct {
+	Path string `yaml:"path"`
+	Days int    `yaml:"days"`
+}
+
+// YaraConfig 定义了临时目录中YARA文件扫描的范围，仅在启用 yara 构建标签时生效
+type YaraConfig struct {
+	MaxDepth      int   `yaml:"max_depth"`        // 最大递归深度，0为不扫描
+	MaxFileSizeMB int64 `yaml:"max_file_size_mb"` // 超过该大小的文件不扫描
+}
+
+// ScoringConfig 定义了主机风险评分模型的参数
+type ScoringConfig struct {
+	RiskWeights     map[string]float64 `yaml:"risk_weights"`     // 每条发现按风险等级计分
+	CategoryWeights map[string]float64 `yaml:"category_weights"` // 按检查项分类调整计分的系数，未配置的分类为 1
+	RepeatDecay     float64            `yaml:"repeat_decay"`     // 同名发现重复出现时，第 n 条按 repeat_decay^(n-1) 计分
+	DefaultRisk     string             `yaml:"default_risk"`     // 风险等级未知的发现按此等级计分
+	CheckRisk       string             `yaml:"check_risk"`       // 没有具体发现的可疑检查项按此等级计分，应低于可疑阈值；未在 risk_weights 中配置时不计分
+	Thresholds      ScoreThresholds    `yaml:"thresholds"`
+}
+
+// ScoreThresholds 定义了评分到结论的阈值，评分达到阈值即判定为对应结论
+type ScoreThresholds struct {
+	Suspicious  int `yaml:"suspicious"`
+	Compromised int `yaml:"compromised"`
+}
+
+// DefaultScoring 返回默认的评分参数: 一条 Critical 的内核发现即判定为疑似失陷，单条 Low 发现仍视为干净
+func DefaultScoring() ScoringConfig {
+	return ScoringConfig{
+		RiskWeights:     map[string]float64{"Low": 2, "Medium": 8, "High": 20, "Critical": 40},
+		CategoryWeights: map[string]float64{"kernel": 1.5, "persistence": 1.2, "filesystem": 0.8},
+		RepeatDecay:     0.5,
+		DefaultRisk:     "Medium",
+		CheckRisk:       "Low",
+		Thresholds:      ScoreThresholds{Suspicious: 5, Compromised: 60},
+	}
+}
+
 // Config 结构体定义了所有可配置的参数
 type Config struct {
-	Output          string `yaml:"output"`
-	MemLimitMB      int64  `yaml:"mem_limit_mb"`
-	ReportOutputDir string `yaml:"report_output_dir"`
-	WebPath         string `yaml:"webpath"`
-	LoginLimit      int    `yaml:"login_limit"`
-	Mtime           struct {
-		Path string `yaml:"path"`
-		Days int    `yaml:"days"`
-	} `yaml:"mtime"`
+	Output           string                 `yaml:"output"`
+	MemLimitMB       int64                  `yaml:"mem_limit_mb"`
+	ReportOutputDir  string                 `yaml:"report_output_dir"`
+	WebPath          string                 `yaml:"webpath"`
+	LoginLimit       int                    `yaml:"login_limit"`
+	Mtime            MtimeConfig            `yaml:"mtime"`
 	SuidDirs         string                 `yaml:"suid_dirs"`
 	HemaPath         string                 `yaml:"hema_path"`
 	HemaResultPath   string                 `yaml:"hema_result_path"`
 	RulesDir         string                 `yaml:"rules_dir"`
 	IOCPath          string                 `yaml:"ioc_path"`
+	IOCFeeds         []string               `yaml:"ioc_feeds"` // STIX 2.1 / MISP 格式的情报文件或目录
 	HistoryFilenames []string               `yaml:"history_filenames"`
 	TempDirs         []string               `yaml:"temp_dirs"`
+	Timeout          TimeoutConfig          `yaml:"timeout"`
+	CheckSelection   CheckSelectionConfig   `yaml:"check_selection"`
+	Yara             YaraConfig             `yaml:"yara"`
+	HashMaxSizeMB    int64                  `yaml:"hash_max_file_size_mb"` // 超过该大小的文件不计算哈希，0为不限制
+	BaselinePath     string                 `yaml:"baseline_path"`
+	SuppressionsPath string                 `yaml:"suppressions_path"`
+	Root             string                 `yaml:"root"`       // 离线分析时被检查文件系统的挂载目录
+	Containers       bool                   `yaml:"containers"` // 是否同时检查宿主机上正在运行的容器
+	Profile          string                 `yaml:"profile"`    // 默认使用的扫描配置档，为空则只使用基础配置
+	Profiles         map[string]Profile     `yaml:"profiles"`   // 自定义扫描配置档，同名时替换内置定义
+	Scoring          ScoringConfig          `yaml:"scoring"`
 	CheckTexts       map[string]CheckConfig `yaml:"check_texts"`
 }
 
@@ -38,15 +101,12 @@ type Config struct {
 func LoadConfig() (*Config, error) {
 	// 设置默认值
 	cfg := &Config{
-		Output:          "md",
-		MemLimitMB:      0,
-		ReportOutputDir: "./reports",
-		WebPath:         "",
-		LoginLimit:      50,
-		Mtime: struct {
-			Path string `yaml:"path"`
-			Days int    `yaml:"days"`
-		}{Path: "/etc", Days: 7},
+		Output:           "md",
+		MemLimitMB:       0,
+		ReportOutputDir:  "./reports",
+		WebPath:          "",
+		LoginLimit:       50,
+		Mtime:            MtimeConfig{Path: "/etc", Days: 7},
 		SuidDirs:         "/",
 		HemaPath:         "./hm",
 		HemaResultPath:   "./result.csv",
@@ -54,6 +114,13 @@ func LoadConfig() (*Config, error) {
 		IOCPath:          "./ioc.yaml",
 		HistoryFilenames: []string{".bash_history", ".zsh_history", ".history"},
 		TempDirs:         []string{"/tmp", "/var/tmp"},
+		Timeout:          TimeoutConfig{Check: 2 * time.Minute, Global: 10 * time.Minute},
+		Yara:             YaraConfig{MaxDepth: 0, MaxFileSizeMB: 10},
+		HashMaxSizeMB:    100,
+		BaselinePath:     "./baseline.json",
+		SuppressionsPath: "./rules/suppressions.yaml",
+		Profiles:         BuiltinProfiles(),
+		Scoring:          DefaultScoring(),
 		CheckTexts:       make(map[string]CheckConfig), // 初始化为空map
 	}
 
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profile 定义了一个扫描配置档。为空(nil)的字段沿用基础配置，已设置的字段整体覆盖基础配置中的对应项
+type Profile struct {
+	Description    string                `yaml:"description"`
+	CheckSelection *CheckSelectionConfig `yaml:"check_selection"`
+	SuidDirs       *string               `yaml:"suid_dirs"`
+	Mtime          *MtimeConfig          `yaml:"mtime"`
+	LoginLimit     *int                  `yaml:"login_limit"`
+	Yara           *YaraConfig           `yaml:"yara"`
+	Timeout        *TimeoutConfig        `yaml:"timeout"`
+}
+
+// BuiltinProfiles 返回程序内置的扫描配置档
+func BuiltinProfiles() map[string]Profile {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	return map[string]Profile{
+		"quick": {
+			Description:    "快速排查: 跳过 Webshell 和隐藏进程检查，SUID 和修改时间只扫描少量目录",
+			CheckSelection: &CheckSelectionConfig{SkipChecks: []string{"WebshellCheck", "HiddenProcessesCheck"}},
+			SuidDirs:       str("/bin,/sbin,/usr/bin,/usr/sbin"),
+			Mtime:          &MtimeConfig{Path: "/etc", Days: 3},
+			LoginLimit:     num(20),
+			Yara:           &YaraConfig{MaxDepth: 0, MaxFileSizeMB: 0},
+			Timeout:        &TimeoutConfig{Check: 30 * time.Second, Global: 3 * time.Minute},
+		},
+		"standard": {
+			Description:    "标准检查: 执行全部检查项，覆盖系统二进制目录和临时目录",
+			CheckSelection: &CheckSelectionConfig{},
+			SuidDirs:       str("/bin,/sbin,/usr/bin,/usr/sbin,/usr/local/bin,/usr/local/sbin,/tmp,/var/tmp,/dev/shm"),
+			Mtime:          &MtimeConfig{Path: "/etc,/var/log", Days: 7},
+			LoginLimit:     num(100),
+			Yara:           &YaraConfig{MaxDepth: 2, MaxFileSizeMB: 10},
+			Timeout:        &TimeoutConfig{Check: 2 * time.Minute, Global: 10 * time.Minute},
+		},
+		"forensic": {
+			Description:    "取证分析: 执行全部检查项，全盘扫描并放宽超时限制",
+			CheckSelection: &CheckSelectionConfig{},
+			SuidDirs:       str("/"),
+			Mtime:          &MtimeConfig{Path: "/etc,/var,/usr,/root,/home,/tmp,/opt", Days: 30},
+			LoginLimit:     num(1000),
+			Yara:           &YaraConfig{MaxDepth: 10, MaxFileSizeMB: 100},
+			Timeout:        &TimeoutConfig{Check: 30 * time.Minute, Global: 2 * time.Hour},
+		},
+	}
+}
+
+// ProfileNames 返回所有可用的扫描配置档名称，按字母排序
+func (c *Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile 将指定的扫描配置档覆盖到基础配置上
+func (c *Config) ApplyProfile(name string) error {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("未知的扫描配置档 '%s' (可用: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	if p.CheckSelection != nil {
+		c.CheckSelection = *p.CheckSelection
+	}
+	if p.SuidDirs != nil {
+		c.SuidDirs = *p.SuidDirs
+	}
+	if p.Mtime != nil {
+		c.Mtime = *p.Mtime
+	}
+	if p.LoginLimit != nil {
+		c.LoginLimit = *p.LoginLimit
+	}
+	if p.Yara != nil {
+		c.Yara = *p.Yara
+	}
+	if p.Timeout != nil {
+		c.Timeout = *p.Timeout
+	}
+	c.Profile = name
+	return nil
+}
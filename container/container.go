@@ -0,0 +1,260 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/keepsea/goDetect/types"
+)
+
+// Container 描述一个正在运行的容器
+type Container struct {
+	ID      string
+	Name    string
+	Image   string
+	Runtime string
+	PID     int // 容器内某个进程在宿主机上的PID，用于通过 /proc/<pid>/root 访问容器文件系统
+}
+
+// Root 返回可从宿主机访问容器根文件系统的路径
+func (c Container) Root() string {
+	return fmt.Sprintf("/proc/%d/root", c.PID)
+}
+
+// Info 返回用于标记检查结果的容器信息
+func (c Container) Info() types.ContainerInfo {
+	return types.ContainerInfo{ID: c.ID, Name: c.Name, Image: c.Image, Runtime: c.Runtime, PID: c.PID}
+}
+
+var (
+	containerIDRe = regexp.MustCompile(`[0-9a-f]{64}`)
+
+	dockerStateDir     = "/var/lib/docker/containers"
+	containerdStateDir = "/run/containerd/io.containerd.runtime.v2.task"
+	podmanStateFiles   = []string{
+		"/var/lib/containers/storage/overlay-containers/containers.json",
+		"/run/containers/storage/overlay-containers/containers.json",
+	}
+)
+
+// Discover 发现宿主机上正在运行的容器。
+// 先从 /proc/<pid>/cgroup 中识别容器ID，再结合各容器运行时的状态目录补充名称、镜像以及未被识别的容器
+func Discover() ([]Container, error) {
+	found := make(map[string]*Container)
+
+	if err := discoverFromCgroups(found); err != nil {
+		return nil, err
+	}
+	discoverFromDocker(found)
+	discoverFromContainerd(found)
+	enrichFromContainersStorage(found)
+
+	var containers []Container
+	for _, c := range found {
+		if c.PID <= 0 || isHostRoot(c.Root()) {
+			continue
+		}
+		if c.Name == "" {
+			c.Name = c.ShortID()
+		}
+		containers = append(containers, *c)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return containers, nil
+}
+
+// ShortID 返回容器ID的前12位，与 docker ps 的展示一致
+func (c Container) ShortID() string {
+	return c.Info().ShortID()
+}
+
+// discoverFromCgroups 遍历所有进程的 cgroup 信息，为每个容器记录其最小的PID
+func discoverFromCgroups(found map[string]*Container) error {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return fmt.Errorf("无法读取 /proc: %w", err)
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cgroup"))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			ids := containerIDRe.FindAllString(line, -1)
+			if len(ids) == 0 {
+				continue
+			}
+			id := ids[len(ids)-1]
+			c, ok := found[id]
+			if !ok {
+				c = &Container{ID: id, Runtime: runtimeFromCgroup(line)}
+				found[id] = c
+			}
+			if c.PID == 0 || pid < c.PID {
+				c.PID = pid
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// runtimeFromCgroup 根据 cgroup 路径推断容器运行时
+func runtimeFromCgroup(line string) string {
+	switch {
+	case strings.Contains(line, "docker"):
+		return "docker"
+	case strings.Contains(line, "containerd"):
+		return "containerd"
+	case strings.Contains(line, "crio"):
+		return "cri-o"
+	case strings.Contains(line, "libpod"):
+		return "podman"
+	}
+	return "unknown"
+}
+
+// discoverFromDocker 读取 Docker 的容器状态文件，获取容器名称、镜像和主进程PID
+func discoverFromDocker(found map[string]*Container) {
+	entries, err := os.ReadDir(dockerStateDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dockerStateDir, entry.Name(), "config.v2.json"))
+		if err != nil {
+			continue
+		}
+		var cfg struct {
+			ID     string `json:"ID"`
+			Name   string `json:"Name"`
+			Config struct {
+				Image string `json:"Image"`
+			} `json:"Config"`
+			State struct {
+				Running bool `json:"Running"`
+				Pid     int  `json:"Pid"`
+			} `json:"State"`
+		}
+		if json.Unmarshal(content, &cfg) != nil || cfg.ID == "" {
+			continue
+		}
+		c, ok := found[cfg.ID]
+		if !ok {
+			if !cfg.State.Running {
+				continue
+			}
+			c = &Container{ID: cfg.ID}
+			found[cfg.ID] = c
+		}
+		c.Runtime = "docker"
+		c.Name = strings.TrimPrefix(cfg.Name, "/")
+		c.Image = cfg.Config.Image
+		if cfg.State.Running && cfg.State.Pid > 0 {
+			c.PID = cfg.State.Pid
+		}
+	}
+}
+
+// discoverFromContainerd 读取 containerd (含 Kubernetes CRI) 的任务状态目录
+func discoverFromContainerd(found map[string]*Container) {
+	taskDirs, err := filepath.Glob(filepath.Join(containerdStateDir, "*", "*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range taskDirs {
+		id := filepath.Base(dir)
+		pidContent, err := os.ReadFile(filepath.Join(dir, "init.pid"))
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(pidContent)))
+		if err != nil {
+			continue
+		}
+		c, ok := found[id]
+		if !ok {
+			c = &Container{ID: id, Runtime: "containerd"}
+			found[id] = c
+		}
+		if c.PID == 0 {
+			c.PID = pid
+		}
+
+		var spec struct {
+			Annotations map[string]string `json:"annotations"`
+		}
+		if content, err := os.ReadFile(filepath.Join(dir, "config.json")); err == nil && json.Unmarshal(content, &spec) == nil {
+			if name := spec.Annotations["io.kubernetes.cri.container-name"]; name != "" && c.Name == "" {
+				c.Name = name
+				if pod := spec.Annotations["io.kubernetes.cri.sandbox-name"]; pod != "" {
+					c.Name = pod + "/" + name
+				}
+			}
+			if image := spec.Annotations["io.kubernetes.cri.image-name"]; image != "" && c.Image == "" {
+				c.Image = image
+			}
+		}
+	}
+}
+
+// enrichFromContainersStorage 从 containers/storage (Podman、CRI-O) 的元数据中补充名称和镜像
+func enrichFromContainersStorage(found map[string]*Container) {
+	for _, path := range podmanStateFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var records []struct {
+			ID       string   `json:"id"`
+			Names    []string `json:"names"`
+			Image    string   `json:"image"`
+			Metadata string   `json:"metadata"`
+		}
+		if json.Unmarshal(content, &records) != nil {
+			continue
+		}
+		for _, r := range records {
+			c, ok := found[r.ID]
+			if !ok {
+				continue
+			}
+			if c.Name == "" && len(r.Names) > 0 {
+				c.Name = r.Names[0]
+			}
+			if c.Image == "" {
+				var meta struct {
+					ImageName string `json:"image-name"`
+				}
+				if json.Unmarshal([]byte(r.Metadata), &meta) == nil && meta.ImageName != "" {
+					c.Image = meta.ImageName
+				} else {
+					c.Image = r.Image
+				}
+			}
+		}
+	}
+}
+
+// isHostRoot 判断给定的根目录是否就是当前进程所在的根文件系统 (如 goDetect 自身运行在容器中时)
+func isHostRoot(root string) bool {
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		return true // 进程已退出或无权限访问，视为不可用
+	}
+	selfInfo, err := os.Stat("/")
+	if err != nil {
+		return false
+	}
+	return os.SameFile(rootInfo, selfInfo)
+}
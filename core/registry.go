@@ -0,0 +1,201 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/keepsea/goDetect/rules"
+)
+
+// Options 包含构造检查项所需的全部运行参数
+type Options struct {
+	RuleEngine        *rules.RuleEngine
+	Root              string // 离线分析时被检查文件系统的根目录，为空表示检查当前运行的系统
+	LoginLimit        int
+	HistoryFilenames  []string
+	SuidDirs          []string
+	MtimePaths        []string
+	MtimeDays         int
+	TempDirs          []string
+	YaraMaxDepth      int
+	YaraMaxFileSizeMB int64
+	HashMaxFileSizeMB int64 // 超过该大小 (MB) 的文件不计算哈希，0为不限制
+	WebPath           string
+	HemaPath          string
+	HemaResultPath    string
+}
+
+// Registration 描述一个已注册的检查项
+type Registration struct {
+	Name     string   // 检查项编程名称，与 Checker.Name() 一致
+	Category string   // 检查项分类，如 account、network、persistence
+	Tags     []string // 附加标签，如 live、slow、ioc
+	Fields   []string // 检查项提供给规则引擎的记录字段，规则可通过 field 指定其一；为空表示未声明
+	New      func(opts Options) Checker
+}
+
+var (
+	registryMu    sync.Mutex
+	registrations []Registration
+)
+
+// Register 注册一个检查项，通常在检查项所在文件的 init 函数中调用
+func Register(reg Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, r := range registrations {
+		if r.Name == reg.Name {
+			panic(fmt.Sprintf("core: 检查项 '%s' 被重复注册", reg.Name))
+		}
+	}
+	registrations = append(registrations, reg)
+}
+
+// Registrations 返回所有已注册的检查项，按注册顺序排列
+func Registrations() []Registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Registration(nil), registrations...)
+}
+
+// categoryLabels 是各分类在报告中显示的名称，与检查项结果中填写的 Category 一致
+var categoryLabels = map[string]string{
+	"account":     "👤 账号安全",
+	"filesystem":  "🗂️ 文件系统",
+	"history":     "📝 命令历史",
+	"kernel":      "🧠 内核与模块",
+	"log":         "📜 日志审计",
+	"network":     "🔌 网络连接",
+	"persistence": "⏰ 持久化机制",
+	"process":     "⚙️ 进程与服务",
+	"web":         "🌐 Web安全",
+}
+
+// categoryLabel 返回检查项分类在报告中显示的名称，未知的检查项或分类返回空字符串
+func categoryLabel(checkName string) string {
+	if reg, ok := Lookup(checkName); ok {
+		return categoryLabels[reg.Category]
+	}
+	return ""
+}
+
+// Lookup 按名称查找已注册的检查项
+func Lookup(name string) (Registration, bool) {
+	for _, r := range Registrations() {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Registration{}, false
+}
+
+// HasField 判断检查项是否向规则引擎提供指定的记录字段。所有记录都带有 line 字段；
+// 未声明字段的检查项 (如字段随日志内容变化的审计日志) 视为提供任意字段
+func (r Registration) HasField(field string) bool {
+	if field == "line" || len(r.Fields) == 0 {
+		return true
+	}
+	for _, f := range r.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Categories 返回所有已注册检查项的分类，按字母排序
+func Categories() []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, r := range Registrations() {
+		if !seen[r.Category] {
+			seen[r.Category] = true
+			categories = append(categories, r.Category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// Selection 定义了检查项的选择条件
+type Selection struct {
+	Checks     []string // 要执行的检查项名称或标签，为空表示不按名称筛选
+	SkipChecks []string // 要跳过的检查项名称、分类或标签
+	Categories []string // 要执行的检查项分类，为空表示不按分类筛选
+}
+
+// HasTag 判断检查项是否带有指定标签
+func (r Registration) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select 根据选择条件筛选已注册的检查项。
+// Checks 与 Categories 取并集，SkipChecks 优先级最高；任何无法识别的名称都会返回错误
+func Select(sel Selection) ([]Registration, error) {
+	all := Registrations()
+	if err := validateSelectors(all, sel); err != nil {
+		return nil, err
+	}
+
+	includeAll := len(sel.Checks) == 0 && len(sel.Categories) == 0
+	var selected []Registration
+	for _, r := range all {
+		included := includeAll
+		for _, s := range sel.Checks {
+			if strings.EqualFold(r.Name, s) || r.HasTag(s) {
+				included = true
+			}
+		}
+		for _, s := range sel.Categories {
+			if strings.EqualFold(r.Category, s) {
+				included = true
+			}
+		}
+		for _, s := range sel.SkipChecks {
+			if strings.EqualFold(r.Name, s) || strings.EqualFold(r.Category, s) || r.HasTag(s) {
+				included = false
+			}
+		}
+		if included {
+			selected = append(selected, r)
+		}
+	}
+	return selected, nil
+}
+
+// validateSelectors 确保所有选择条件都能对应到至少一个已注册的检查项，避免拼写错误被静默忽略
+func validateSelectors(all []Registration, sel Selection) error {
+	known := func(s string, byName, byCategory, byTag bool) bool {
+		for _, r := range all {
+			if (byName && strings.EqualFold(r.Name, s)) ||
+				(byCategory && strings.EqualFold(r.Category, s)) ||
+				(byTag && r.HasTag(s)) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, s := range sel.Checks {
+		if !known(s, true, false, true) {
+			return fmt.Errorf("未知的检查项或标签: '%s'", s)
+		}
+	}
+	for _, s := range sel.SkipChecks {
+		if !known(s, true, true, true) {
+			return fmt.Errorf("未知的检查项、分类或标签: '%s'", s)
+		}
+	}
+	for _, s := range sel.Categories {
+		if !known(s, false, true, false) {
+			return fmt.Errorf("未知的检查项分类: '%s' (可用分类: %s)", s, strings.Join(Categories(), ", "))
+		}
+	}
+	return nil
+}
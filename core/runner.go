@@ -1,9 +1,147 @@
 package core
 
-import "github.com/keepsea/goDetect/types"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/keepsea/goDetect/types"
+)
 
 // Checker 是所有检查项都必须实现的接口
 type Checker interface {
-	Execute() []types.CheckResult
+	// Execute 执行检查。实现应在耗时操作之间检查 ctx，并在 ctx 结束后尽快返回已收集的部分结果
+	Execute(ctx context.Context) []types.CheckResult
 	Name() string // 返回检查项的编程名称，用于查找配置
 }
+
+// DefaultGracePeriod 是检查项超时后，等待其返回部分结果的宽限时间
+const DefaultGracePeriod = 5 * time.Second
+
+// Runner 负责并发执行检查项，并为每个检查项施加超时控制
+type Runner struct {
+	CheckTimeout time.Duration // 单个检查项的超时时间，0为不限制
+	GracePeriod  time.Duration // 超时后等待检查项返回部分结果的时间，0则使用 DefaultGracePeriod
+	// OnComplete 在每个检查项结束时被调用，用于输出进度，可为 nil。container 为检查目标容器，宿主机检查项为 nil
+	OnComplete func(checkName string, container *types.ContainerInfo, completed, total int, timedOut bool)
+}
+
+// Run 并发执行所有检查项并汇总结果。全局超时通过 ctx 传入
+func (r Runner) Run(ctx context.Context, checkers []Checker) []types.CheckResult {
+	var wg sync.WaitGroup
+	resultsChan := make(chan []types.CheckResult, len(checkers))
+	var completedChecks int32
+	totalChecks := len(checkers)
+
+	for _, chk := range checkers {
+		wg.Add(1)
+		go func(c Checker) {
+			defer wg.Done()
+
+			results := r.runOne(ctx, c)
+			cc, inContainer := c.(containerChecker)
+			timedOut := false
+			for i := range results {
+				results[i].CheckName = c.Name()
+				if inContainer {
+					info := cc.info
+					results[i].Container = &info
+				}
+				timedOut = timedOut || results[i].Status == types.StatusTimedOut
+			}
+			resultsChan <- results
+
+			currentCount := atomic.AddInt32(&completedChecks, 1)
+			if r.OnComplete != nil {
+				var container *types.ContainerInfo
+				if inContainer {
+					container = &cc.info
+				}
+				r.OnComplete(c.Name(), container, int(currentCount), totalChecks, timedOut)
+			}
+		}(chk)
+	}
+
+	wg.Wait()
+	close(resultsChan)
+
+	var allResults []types.CheckResult
+	for res := range resultsChan {
+		allResults = append(allResults, res...)
+	}
+	return allResults
+}
+
+// runOne 在独立的超时上下文中执行单个检查项
+func (r Runner) runOne(parent context.Context, c Checker) []types.CheckResult {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if r.CheckTimeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, r.CheckTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+
+	// 缓冲为1，确保检查项在宽限期后才返回时不会阻塞泄漏的 goroutine
+	done := make(chan []types.CheckResult, 1)
+	go func() {
+		done <- c.Execute(ctx)
+	}()
+
+	var results []types.CheckResult
+	timedOut := false
+	select {
+	case results = <-done:
+	case <-ctx.Done():
+		// 只有在检查项返回之前上下文结束才视为超时；检查项正常返回后恰好到期不影响其结果
+		timedOut = true
+		grace := r.GracePeriod
+		if grace <= 0 {
+			grace = DefaultGracePeriod
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case results = <-done:
+		case <-timer.C:
+			// 检查项未响应取消（如阻塞在不可中断的文件系统调用上），放弃等待
+			results = []types.CheckResult{{Category: categoryLabel(c.Name()), Details: "检查项在超时后未能及时返回，未收集到任何输出。"}}
+		}
+	}
+
+	if timedOut {
+		markTimedOut(results, ctx.Err(), r.CheckTimeout)
+	}
+	return results
+}
+
+// markTimedOut 将结果标记为超时，并保留检查项已收集到的部分输出
+func markTimedOut(results []types.CheckResult, err error, timeout time.Duration) {
+	reason := "检查被取消"
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = "检查超时"
+		if timeout > 0 {
+			reason = fmt.Sprintf("检查超时 (超过 %s)", timeout)
+		}
+	}
+	for i := range results {
+		results[i].Status = types.StatusTimedOut
+		results[i].Result = reason
+		results[i].Details = "--- " + reason + "，以下为已收集的部分输出 ---\n" + results[i].Details
+	}
+}
+
+// containerChecker 包装一个以容器根文件系统为检查目标的检查项，执行器会为其结果标记所属容器
+type containerChecker struct {
+	Checker
+	info types.ContainerInfo
+}
+
+// ForContainer 返回一个结果会被标记为属于指定容器的检查项
+func ForContainer(c Checker, info types.ContainerInfo) Checker {
+	return containerChecker{Checker: c, info: info}
+}
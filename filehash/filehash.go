@@ -0,0 +1,86 @@
+// Package filehash 计算文件的 MD5、SHA-1 和 SHA-256，并按文件的设备号和 inode 缓存结果，
+// 同一个可执行文件被多个进程使用或被多个检查项引用时只需读取一次
+package filehash
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Sums 是一个文件的哈希值，均为小写十六进制
+type Sums struct {
+	MD5    string
+	SHA1   string
+	SHA256 string
+}
+
+type cacheKey struct {
+	dev, ino uint64
+}
+
+type cacheEntry struct {
+	mtime time.Time
+	size  int64
+	sums  Sums
+}
+
+var (
+	mu    sync.Mutex
+	cache = make(map[cacheKey]cacheEntry)
+)
+
+// Sum 计算文件的哈希值，path 可以是 /proc/<pid>/exe 这样的链接 (文件已被删除时同样有效)。
+// 文件的 inode、修改时间和大小都未变化时直接返回缓存的结果。maxSize 大于 0 时，超过该大小的文件返回错误
+func Sum(path string, maxSize int64) (Sums, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Sums{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Sums{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Sums{}, fmt.Errorf("'%s' 不是普通文件", path)
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return Sums{}, fmt.Errorf("'%s' 超过 %d MB, 未计算哈希", path, maxSize/1024/1024)
+	}
+
+	var key cacheKey
+	st, cacheable := info.Sys().(*syscall.Stat_t)
+	if cacheable {
+		key = cacheKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+		mu.Lock()
+		entry, ok := cache[key]
+		mu.Unlock()
+		if ok && entry.mtime.Equal(info.ModTime()) && entry.size == info.Size() {
+			return entry.sums, nil
+		}
+	}
+
+	h5, h1, h256 := md5.New(), sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(h5, h1, h256), f); err != nil {
+		return Sums{}, err
+	}
+	sums := Sums{
+		MD5:    hex.EncodeToString(h5.Sum(nil)),
+		SHA1:   hex.EncodeToString(h1.Sum(nil)),
+		SHA256: hex.EncodeToString(h256.Sum(nil)),
+	}
+	if cacheable {
+		mu.Lock()
+		cache[key] = cacheEntry{mtime: info.ModTime(), size: info.Size(), sums: sums}
+		mu.Unlock()
+	}
+	return sums, nil
+}
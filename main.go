@@ -2,24 +2,30 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"runtime/debug"
 	"strings"
-	"sync"
-	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/keepsea/goDetect/checks"
+	// 导入 checks 包以触发各检查项的注册
+	"github.com/keepsea/goDetect/baseline"
+	_ "github.com/keepsea/goDetect/checks"
 	"github.com/keepsea/goDetect/config"
+	"github.com/keepsea/goDetect/container"
 	"github.com/keepsea/goDetect/core"
 	"github.com/keepsea/goDetect/report"
 	"github.com/keepsea/goDetect/rules"
+	"github.com/keepsea/goDetect/scoring"
 	"github.com/keepsea/goDetect/types"
 	"github.com/keepsea/goDetect/utils"
 	"github.com/keepsea/goDetect/validation"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -40,8 +46,8 @@ const (
 		"\n"
 )
 
-func getOSInfo() (string, error) {
-	content, err := ioutil.ReadFile("/etc/os-release")
+func getOSInfo(root string) (string, error) {
+	content, err := ioutil.ReadFile(utils.HostPath(root, "/etc/os-release"))
 	if err == nil {
 		scanner := strings.NewReader(string(content))
 		bufScanner := bufio.NewScanner(scanner)
@@ -52,14 +58,128 @@ func getOSInfo() (string, error) {
 			}
 		}
 	}
-	return utils.RunCommand("uname", "-a")
+	if root != "" {
+		return "", err
+	}
+	return utils.RunCommand(context.Background(), "uname", "-a")
+}
+
+// getHostname 获取被检查系统的主机名，离线模式下读取镜像中的 /etc/hostname
+func getHostname(root string) (string, error) {
+	if root == "" {
+		return os.Hostname()
+	}
+	content, err := ioutil.ReadFile(utils.HostPath(root, "/etc/hostname"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// splitList 将逗号分隔的参数拆分为列表，并去除空白项
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// applySuppressions 使用抑制规则过滤各检查项的发现，返回被抑制的发现总数。
+// 发现全部被抑制的可疑结果恢复为正常，结果描述中注明被抑制的条数；
+// 没有具体发现的可疑结果由屏蔽整个检查项的抑制规则处理，结果本身记为一条被抑制的发现
+func applySuppressions(engine *rules.RuleEngine, results []types.CheckResult) int {
+	total := 0
+	for i := range results {
+		r := &results[i]
+		if len(r.Findings) == 0 && r.Status == types.StatusSuspicious {
+			if s, ok := engine.SuppressCheck(r.CheckName, r.Result); ok {
+				total++
+				r.Suppressed = append(r.Suppressed, s)
+				r.Status, r.Result = types.StatusOK, "检查项已被抑制规则屏蔽 (原结果: "+r.Result+")"
+			}
+			continue
+		}
+		kept, suppressed := engine.Suppress(r.CheckName, r.Findings)
+		if len(suppressed) == 0 {
+			continue
+		}
+		total += len(suppressed)
+		r.Findings, r.Suppressed = kept, suppressed
+		if len(kept) == 0 && r.Status == types.StatusSuspicious {
+			r.Status, r.Result = types.StatusOK, "所有发现均已被抑制规则屏蔽"
+		}
+		r.Result += fmt.Sprintf(" (已抑制 %d 条发现)", len(suppressed))
+	}
+	return total
+}
+
+// runSigmaConvert 执行 sigma convert 子命令: 将 Sigma 规则文件或目录转换为 goDetect 规则文件，
+// 并列出无法转换的规则及原因。返回进程退出码
+func runSigmaConvert(args []string) int {
+	fs := flag.NewFlagSet("sigma convert", flag.ExitOnError)
+	output := fs.String("o", "", "输出的规则文件路径，为空时输出到标准输出")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: goDetect sigma convert [-o rules/sigma_rules.yaml] <Sigma规则文件或目录>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var converted []rules.Rule
+	var issues []rules.SigmaIssue
+	for _, path := range fs.Args() {
+		r, i, err := rules.LoadSigma(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "严重错误: 无法读取 '%s': %v\n", path, err)
+			return 1
+		}
+		converted, issues = append(converted, r...), append(issues, i...)
+	}
+
+	content, err := yaml.Marshal(rules.RuleFile{Rules: converted})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "严重错误: 生成规则文件失败: %v\n", err)
+		return 1
+	}
+	content = append([]byte("# 由 goDetect sigma convert 从 Sigma 规则转换生成\n"), content...)
+	if *output == "" {
+		os.Stdout.Write(content)
+	} else if err := ioutil.WriteFile(*output, content, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "严重错误: 无法写入 '%s': %v\n", *output, err)
+		return 1
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "跳过: %s\n", issue)
+	}
+	fmt.Fprintf(os.Stderr, "已转换 %d 条规则，跳过 %d 条无法转换的规则\n", len(converted), len(issues))
+	return 0
+}
+
+// printChecks 打印所有已注册的检查项，供 -checks/-skip-checks/-categories 参考
+func printChecks() {
+	fmt.Printf("%-30s %-12s %s\n", "NAME", "CATEGORY", "TAGS")
+	for _, reg := range core.Registrations() {
+		fmt.Printf("%-30s %-12s %s\n", reg.Name, reg.Category, strings.Join(reg.Tags, ","))
+	}
 }
 
 func main() {
-	fmt.Println(Banner)
+	// sigma convert 的转换结果可能输出到标准输出，需在打印横幅之前处理
+	if args := os.Args[1:]; len(args) >= 2 && args[0] == "sigma" && args[1] == "convert" {
+		os.Exit(runSigmaConvert(args[2:]))
+	}
+
+	fmt.Print(Banner)
 	fmt.Printf(" GoDetect - Version %s\n", Version)
 	fmt.Println("==========================================================")
-	fmt.Printf("             安全源自未雨绸缪,隐患常藏字节之间！！!              %s\n")
+	fmt.Println("             安全源自未雨绸缪,隐患常藏字节之间！！!")
 	fmt.Println("==========================================================")
 
 	// 1. 加载配置文件
@@ -69,8 +189,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	// 2. 定义所有命令行参数
-	validateRules := flag.Bool("validate-rules", false, "只验证规则文件的正确性，不执行扫描")
+	// 2. 解析子命令并定义所有命令行参数
+	// 支持的子命令: baseline save (保存基线快照)、sigma convert (转换 Sigma 规则)；不带子命令时执行常规扫描
+	args := os.Args[1:]
+	saveBaseline := false
+	if len(args) >= 2 && args[0] == "baseline" && args[1] == "save" {
+		saveBaseline = true
+		args = args[2:]
+	}
+	validateRules := flag.Bool("validate-rules", false, "只验证规则文件的正确性并执行规则内嵌的测试用例，不执行扫描")
+	validateOutput := flag.String("validate-output", "", "将 -validate-rules 的验证结果以 JSON 格式保存到指定文件，供 CI 使用")
 	outputFormat := flag.String("output", cfg.Output, "报告输出格式 (md, json)")
 	memLimitMB := flag.Int64("mem-limit-mb", cfg.MemLimitMB, "设置程序的最大内存使用限制 (MB)，0为不限制")
 	reportOutputDir := flag.String("report-dir", cfg.ReportOutputDir, "报告输出目录")
@@ -83,18 +211,79 @@ func main() {
 	hemaResultPath := flag.String("hema-result-path", cfg.HemaResultPath, "河马工具扫描结果的输出路径")
 	rulesDir := flag.String("rules-dir", cfg.RulesDir, "安全检测规则文件所在的目录")
 	iocPath := flag.String("ioc-path", cfg.IOCPath, "威胁情报库 (IOC) 文件路径")
+	iocFeeds := flag.String("ioc-feeds", strings.Join(cfg.IOCFeeds, ","), "要导入的 STIX 2.1 / MISP 情报文件或目录 (逗号分隔)")
 	historyFilenames := flag.String("history-filenames", strings.Join(cfg.HistoryFilenames, ","), "要检查的命令历史文件名列表 (逗号分隔)")
 	tempDirs := flag.String("temp-dirs", strings.Join(cfg.TempDirs, ","), "要检查的临时目录列表 (逗号分隔)")
-	flag.Parse()
+	checkTimeout := flag.Duration("check-timeout", cfg.Timeout.Check, "单个检查项的超时时间 (如 90s、2m)，0为不限制")
+	checkNames := flag.String("checks", strings.Join(cfg.CheckSelection.Checks, ","), "只执行指定的检查项名称或标签 (逗号分隔)")
+	skipChecks := flag.String("skip-checks", strings.Join(cfg.CheckSelection.SkipChecks, ","), "跳过指定的检查项名称、分类或标签 (逗号分隔)")
+	categories := flag.String("categories", strings.Join(cfg.CheckSelection.Categories, ","), "只执行指定分类的检查项 (逗号分隔)")
+	listChecks := flag.Bool("list-checks", false, "列出所有可用的检查项、分类和标签后退出")
+	globalTimeout := flag.Duration("global-timeout", cfg.Timeout.Global, "整次扫描的超时时间 (如 10m)，0为不限制")
+	yaraMaxDepth := flag.Int("yara-max-depth", cfg.Yara.MaxDepth, "YARA扫描临时目录的最大递归深度，0为不扫描 (仅YARA版本有效)")
+	yaraMaxSizeMB := flag.Int64("yara-max-size-mb", cfg.Yara.MaxFileSizeMB, "超过该大小(MB)的文件不进行YARA扫描，0为不限制")
+	hashMaxSizeMB := flag.Int64("hash-max-size-mb", cfg.HashMaxSizeMB, "超过该大小(MB)的文件不计算哈希，0为不限制")
+	profileName := flag.String("profile", cfg.Profile, "扫描配置档 (quick, standard, forensic 或配置文件中自定义的名称)")
+	rootDir := flag.String("root", cfg.Root, "离线分析模式: 被检查文件系统的挂载目录 (如磁盘镜像或容器rootfs)，依赖运行中系统的检查项将被跳过")
+	scanContainers := flag.Bool("containers", cfg.Containers, "同时检查宿主机上正在运行的容器，对每个容器的文件系统执行基于文件的检查项")
+	baselinePath := flag.String("baseline", cfg.BaselinePath, "基线快照文件路径，文件存在时扫描结果将与其比对；为空则不比对")
+	suppressionsPath := flag.String("suppressions", cfg.SuppressionsPath, "抑制规则文件路径，用于屏蔽已确认为正常的发现；为空则不启用抑制")
+	flag.CommandLine.Parse(args)
+
+	// 应用扫描配置档: 配置档覆盖基础配置，命令行中显式指定的参数优先级最高
+	if *profileName != "" {
+		if err := cfg.ApplyProfile(*profileName); err != nil {
+			fmt.Printf("严重错误: %v\n", err)
+			os.Exit(1)
+		}
+		explicit := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		profileValues := map[string]func(){
+			"checks":           func() { *checkNames = strings.Join(cfg.CheckSelection.Checks, ",") },
+			"skip-checks":      func() { *skipChecks = strings.Join(cfg.CheckSelection.SkipChecks, ",") },
+			"categories":       func() { *categories = strings.Join(cfg.CheckSelection.Categories, ",") },
+			"suid-dirs":        func() { *suidDirs = cfg.SuidDirs },
+			"mtime-path":       func() { *mtimePath = cfg.Mtime.Path },
+			"mtime-days":       func() { *mtimeDays = cfg.Mtime.Days },
+			"login-limit":      func() { *loginLimit = cfg.LoginLimit },
+			"yara-max-depth":   func() { *yaraMaxDepth = cfg.Yara.MaxDepth },
+			"yara-max-size-mb": func() { *yaraMaxSizeMB = cfg.Yara.MaxFileSizeMB },
+			"check-timeout":    func() { *checkTimeout = cfg.Timeout.Check },
+			"global-timeout":   func() { *globalTimeout = cfg.Timeout.Global },
+		}
+		for name, apply := range profileValues {
+			if !explicit[name] {
+				apply()
+			}
+		}
+		fmt.Printf("使用扫描配置档: %s (%s)\n", *profileName, cfg.Profiles[*profileName].Description)
+	}
+
+	if *rootDir != "" {
+		if info, err := os.Stat(*rootDir); err != nil || !info.IsDir() {
+			fmt.Printf("严重错误: -root 指定的目录 '%s' 不存在或不是目录\n", *rootDir)
+			os.Exit(1)
+		}
+		fmt.Printf("离线分析模式: 检查挂载于 '%s' 的文件系统\n", *rootDir)
+		if *scanContainers {
+			fmt.Println("警告: 离线分析模式下无法发现运行中的容器，已忽略 -containers 参数")
+			*scanContainers = false
+		}
+	}
 
 	// 3. 规则验证模式
 	if *validateRules {
-		if !validation.ValidateRules(*rulesDir, *iocPath) {
+		if !validation.ValidateRules(*rulesDir, *iocPath, *suppressionsPath, splitList(*iocFeeds), *validateOutput) {
 			os.Exit(1)
 		}
 		os.Exit(0)
 	}
 
+	if *listChecks {
+		printChecks()
+		os.Exit(0)
+	}
+
 	// 4. 应用内存限制
 	if *memLimitMB > 0 {
 		debug.SetMemoryLimit(*memLimitMB * 1024 * 1024)
@@ -108,6 +297,7 @@ func main() {
 		fmt.Printf("严重错误: 规则引擎初始化失败: %v\n", err)
 		os.Exit(1)
 	}
+	ruleEngine.LoadIOCFeeds(splitList(*iocFeeds), time.Now())
 	fmt.Println("Rules and IOCs loaded successfully.")
 
 	// 6. 初始化报告数据
@@ -115,114 +305,167 @@ func main() {
 		Timestamp:   time.Now().Format("2006-01-02 15:04:05 MST"),
 		GeneratedBy: "Kylin Host Compromise Check Tool " + Version,
 	}
-	hostname, err := os.Hostname()
+	hostname, err := getHostname(*rootDir)
 	if err == nil {
 		reportData.Hostname = hostname
 	}
-	osInfo, err := getOSInfo()
+	osInfo, err := getOSInfo(*rootDir)
 	if err == nil {
 		reportData.OSInfo = osInfo
 	}
+	if err := ruleEngine.LoadSuppressions(*suppressionsPath, reportData.Hostname, time.Now()); err != nil {
+		fmt.Printf("警告: 无法加载抑制规则，所有发现都将被报告: %v\n", err)
+	}
+	reportData.ExpiredSuppressions = ruleEngine.ExpiredSuppressions()
+	for _, s := range reportData.ExpiredSuppressions {
+		fmt.Printf("警告: 抑制规则已于 %s 过期，不再生效 (%s, 负责人: %s)\n", s.Expires, s, s.Owner)
+	}
 
-	// 7. 使用最终配置来初始化检查项
-	checksToRun := []core.Checker{
-		checks.RootAccountsCheck{RuleEngine: ruleEngine},
-		checks.EmptyPasswordAccountsCheck{RuleEngine: ruleEngine},
-		checks.SudoersCheck{RuleEngine: ruleEngine},
-		checks.LastLoginsCheck{RuleEngine: ruleEngine, Limit: *loginLimit},
-		checks.FailedLoginsCheck{RuleEngine: ruleEngine},
-		checks.HistoryCheck{RuleEngine: ruleEngine, Filenames: strings.Split(*historyFilenames, ",")},
-		checks.SuspiciousProcessesCheck{RuleEngine: ruleEngine},
-		checks.DeletedRunningProcessesCheck{RuleEngine: ruleEngine},
-		checks.ListeningPortsCheck{RuleEngine: ruleEngine},
-		checks.EstablishedConnectionsCheck{RuleEngine: ruleEngine},
-		checks.PromiscuousModeCheck{RuleEngine: ruleEngine},
-		checks.SuidSgidFilesCheck{RuleEngine: ruleEngine, Dirs: strings.Split(*suidDirs, ",")},
-		checks.RecentlyModifiedFilesCheck{RuleEngine: ruleEngine, Paths: strings.Split(*mtimePath, ","), Days: *mtimeDays},
-		checks.TempDirsCheck{RuleEngine: ruleEngine, TempDirs: strings.Split(*tempDirs, ",")},
-		checks.CronJobsCheck{RuleEngine: ruleEngine},
-		checks.SystemdTimersCheck{RuleEngine: ruleEngine},
-		checks.KernelModulesCheck{RuleEngine: ruleEngine},
-	}
-	if *webPath != "" {
-		checksToRun = append(checksToRun, checks.WebshellCheck{
-			RuleEngine:     ruleEngine,
-			WebPath:        *webPath,
-			HemaPath:       *hemaPath,
-			HemaResultPath: *hemaResultPath,
-		})
-	}
-
-	// 8. 并发执行所有检查并填充元数据
-	fmt.Println("\n--- Starting Checks ---")
-	var allResults []types.CheckResult
-	var wg sync.WaitGroup
-	resultsChan := make(chan []types.CheckResult, len(checksToRun))
-	var completedChecks int32
-	totalChecks := len(checksToRun)
-
-	for _, chk := range checksToRun {
-		wg.Add(1)
-		go func(c core.Checker) {
-			defer wg.Done()
-
-			results := c.Execute()
-			checkName := c.Name()
+	// 7. 根据选择条件，使用最终配置来初始化检查项
+	selection := core.Selection{
+		Checks:     splitList(*checkNames),
+		SkipChecks: splitList(*skipChecks),
+		Categories: splitList(*categories),
+	}
+	if saveBaseline {
+		// 保存基线时只执行能产生基线数据的检查项
+		selection.Checks, selection.Categories = []string{"baseline"}, nil
+	}
+	selected, err := core.Select(selection)
+	if err != nil {
+		fmt.Printf("严重错误: 检查项选择无效: %v\n", err)
+		os.Exit(1)
+	}
+	opts := core.Options{
+		RuleEngine:        ruleEngine,
+		Root:              *rootDir,
+		LoginLimit:        *loginLimit,
+		HistoryFilenames:  splitList(*historyFilenames),
+		SuidDirs:          splitList(*suidDirs),
+		MtimePaths:        splitList(*mtimePath),
+		MtimeDays:         *mtimeDays,
+		TempDirs:          splitList(*tempDirs),
+		YaraMaxDepth:      *yaraMaxDepth,
+		YaraMaxFileSizeMB: *yaraMaxSizeMB,
+		HashMaxFileSizeMB: *hashMaxSizeMB,
+		WebPath:           *webPath,
+		HemaPath:          *hemaPath,
+		HemaResultPath:    *hemaResultPath,
+	}
+	var checksToRun []core.Checker
+	for _, reg := range selected {
+		checksToRun = append(checksToRun, reg.New(opts))
+	}
 
-			// 为结果填充元数据
-			if meta, ok := cfg.CheckTexts[checkName]; ok {
-				for i := range results {
-					// 如果检查项本身没有设置Description，则使用配置文件的
-					if results[i].Description == "" {
-						results[i].Description = meta.Description
-					}
-					results[i].Explanation = meta.Explanation
+	// 7.1 发现运行中的容器，通过 /proc/<pid>/root 对每个容器执行基于文件的检查项
+	if *scanContainers && !saveBaseline {
+		containers, err := container.Discover()
+		if err != nil {
+			fmt.Printf("警告: 容器发现失败，跳过容器检查: %v\n", err)
+		}
+		for _, ctr := range containers {
+			fmt.Printf("发现容器: %s (镜像: %s, 运行时: %s, PID: %d)\n", ctr.Name, ctr.Image, ctr.Runtime, ctr.PID)
+			containerOpts := opts
+			containerOpts.Root = ctr.Root()
+			for _, reg := range selected {
+				if reg.HasTag("container") {
+					checksToRun = append(checksToRun, core.ForContainer(reg.New(containerOpts), ctr.Info()))
 				}
 			}
+		}
+		if err == nil && len(containers) == 0 {
+			fmt.Println("未发现正在运行的容器")
+		}
+	}
+	if len(checksToRun) == 0 {
+		fmt.Println("严重错误: 根据选择条件没有任何检查项需要执行")
+		os.Exit(1)
+	}
 
-			resultsChan <- results
-
-			// 更新进度
-			atomic.AddInt32(&completedChecks, 1)
-			currentCount := atomic.LoadInt32(&completedChecks)
-			percent := (float64(currentCount) / float64(totalChecks)) * 100
+	// 8. 并发执行所有检查并填充元数据，Ctrl+C 或全局超时会中止仍在运行的检查
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *globalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *globalTimeout)
+		defer cancel()
+	}
 
+	fmt.Println("\n--- Starting Checks ---")
+	runner := core.Runner{
+		CheckTimeout: *checkTimeout,
+		OnComplete: func(checkName string, ctr *types.ContainerInfo, completed, total int, timedOut bool) {
+			percent := (float64(completed) / float64(total)) * 100
 			desc := checkName
 			if meta, ok := cfg.CheckTexts[checkName]; ok {
 				desc = meta.Description
 			}
-			fmt.Printf("✔ [%d/%d] (%.0f%%) Completed: %s\n", currentCount, totalChecks, percent, desc)
-		}(chk)
+			if ctr != nil {
+				desc += " [容器: " + ctr.Name + "]"
+			}
+			if timedOut {
+				fmt.Printf("✘ [%d/%d] (%.0f%%) Timed out: %s\n", completed, total, percent, desc)
+				return
+			}
+			fmt.Printf("✔ [%d/%d] (%.0f%%) Completed: %s\n", completed, total, percent, desc)
+		},
 	}
+	allResults := runner.Run(ctx, checksToRun)
 
-	wg.Wait()
-	close(resultsChan)
-
-	for res := range resultsChan {
-		allResults = append(allResults, res...)
+	// 为结果填充元数据
+	for i := range allResults {
+		if meta, ok := cfg.CheckTexts[allResults[i].CheckName]; ok {
+			// 如果检查项本身没有设置Description，则使用配置文件的
+			if allResults[i].Description == "" {
+				allResults[i].Description = meta.Description
+			}
+			allResults[i].Explanation = meta.Explanation
+		}
 	}
 	fmt.Println("--- All Checks Completed ---")
 
-	if *webPath == "" {
-		allResults = append(allResults, types.CheckResult{
-			Category:    "🌐 Web安全",
-			Description: "Webshell 检测",
-			Result:      "[跳过]",
-			Explanation: "通过 `-webpath` 参数可以指定Web目录，以启用此项检查。",
-			Details:     "未提供 -webpath 参数，已跳过 Webshell 检测。",
-		})
+	// 8.1 保存基线，或与已有基线进行比对
+	if saveBaseline {
+		if *baselinePath == "" {
+			fmt.Println("严重错误: 未指定基线文件路径，请使用 -baseline 参数")
+			os.Exit(1)
+		}
+		b := baseline.FromResults(reportData.Hostname, allResults)
+		if err := b.Save(*baselinePath); err != nil {
+			fmt.Printf("严重错误: %v\n", err)
+			os.Exit(1)
+		}
+		for _, r := range allResults {
+			if r.Status == types.StatusError || r.Status == types.StatusTimedOut {
+				fmt.Printf("警告: 检查项 %s 未能完整采集数据 (%s)，未写入基线\n", r.CheckName, r.Result)
+			}
+		}
+		fmt.Printf("基线已保存: %s (%d 个检查项)\n", *baselinePath, len(b.Artifacts))
+		os.Exit(0)
+	}
+	if *baselinePath != "" {
+		b, err := baseline.Load(*baselinePath)
+		switch {
+		case os.IsNotExist(err):
+			fmt.Printf("未找到基线文件 '%s'，跳过基线比对。可使用 'baseline save' 子命令创建基线。\n", *baselinePath)
+		case err != nil:
+			fmt.Printf("警告: 无法加载基线，跳过基线比对: %v\n", err)
+		default:
+			drift := b.Compare(allResults)
+			fmt.Printf("已与基线 (%s) 比对，发现 %d 处变化\n", b.CreatedAt, drift)
+		}
+	}
+
+	// 8.2 应用抑制规则，屏蔽已确认为正常的发现
+	if suppressed := applySuppressions(ruleEngine, allResults); suppressed > 0 {
+		fmt.Printf("已根据抑制规则屏蔽 %d 条发现\n", suppressed)
 	}
 
 	// 9. 统计结果
 	reportData.Checks = allResults
-	reportData.TotalChecks = len(allResults)
-	var suspiciousCount int
-	for _, check := range allResults {
-		if check.IsSuspicious {
-			suspiciousCount++
-		}
-	}
-	reportData.SuspiciousCount = suspiciousCount
+	reportData.CountStatuses()
+	reportData.GroupByTactic()
+	reportData.Score = scoring.Compute(allResults, cfg.Scoring)
 
 	// 10. 根据参数选择报告生成器并生成报告
 	var reportGenerator report.Generator
@@ -240,4 +483,8 @@ func main() {
 	if err != nil {
 		fmt.Printf("错误: 生成报告失败: %v\n", err)
 	}
+
+	// 11. 输出主机风险评分，并以结论作为退出码: 0 干净、3 可疑、4 疑似失陷
+	fmt.Printf("主机风险评分: %d/100 (%s)\n", reportData.Score.Score, reportData.Score.Verdict.Label())
+	os.Exit(reportData.Score.Verdict.ExitCode())
 }
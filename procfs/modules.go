@@ -0,0 +1,189 @@
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Module 是一个已加载的内核模块
+type Module struct {
+	Name      string
+	Size      int
+	RefCount  int
+	Deps      []string
+	State     string // Live、Loading 或 Unloading
+	TaintFlag string // 模块的污染标记，如 "OE"，未污染时为空
+}
+
+// Modules 解析 /proc/modules
+func Modules() ([]Module, error) {
+	f, err := os.Open("/proc/modules")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var modules []Module
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式: name size refcnt deps state address [(taint)]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		m := Module{Name: fields[0], State: fields[4]}
+		m.Size, _ = strconv.Atoi(fields[1])
+		m.RefCount, _ = strconv.Atoi(fields[2])
+		for _, dep := range strings.Split(strings.TrimSuffix(fields[3], ","), ",") {
+			if dep != "-" && dep != "" {
+				m.Deps = append(m.Deps, dep)
+			}
+		}
+		if len(fields) > 6 {
+			m.TaintFlag = strings.Trim(fields[6], "()")
+		}
+		modules = append(modules, m)
+	}
+	return modules, scanner.Err()
+}
+
+// SysModule 是 /sys/module 下的一个可加载模块条目
+type SysModule struct {
+	Name      string
+	InitState string // live、coming 或 going
+	RefCount  int
+	Taint     string
+}
+
+// SysModules 读取 /sys/module 下的可加载模块。编译进内核的模块没有 initstate 文件，不会被返回
+func SysModules() ([]SysModule, error) {
+	entries, err := os.ReadDir("/sys/module")
+	if err != nil {
+		return nil, err
+	}
+	var modules []SysModule
+	for _, entry := range entries {
+		dir := filepath.Join("/sys/module", entry.Name())
+		initState, err := readTrimmed(filepath.Join(dir, "initstate"))
+		if err != nil {
+			continue
+		}
+		m := SysModule{Name: entry.Name(), InitState: initState}
+		if refcnt, err := readTrimmed(filepath.Join(dir, "refcnt")); err == nil {
+			m.RefCount, _ = strconv.Atoi(refcnt)
+		}
+		m.Taint, _ = readTrimmed(filepath.Join(dir, "taint"))
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// taintFlags 对应内核 Documentation/admin-guide/tainted-kernels.rst 中各个位的含义
+var taintFlags = []struct {
+	Letter      string
+	Description string
+}{
+	{"P", "加载了专有许可证的模块"},
+	{"F", "模块被强制加载"},
+	{"S", "内核运行在不符合规范的系统上"},
+	{"R", "模块被强制卸载"},
+	{"M", "发生过机器检查异常"},
+	{"B", "发现错误的内存页引用"},
+	{"U", "用户空间请求设置了污染标记"},
+	{"D", "内核发生过 oops 或 BUG"},
+	{"A", "ACPI 表被覆盖"},
+	{"W", "内核发出过警告"},
+	{"C", "加载了 staging 驱动"},
+	{"I", "应用了平台固件缺陷的规避措施"},
+	{"O", "加载了树外 (out-of-tree) 模块"},
+	{"E", "加载了未签名的模块"},
+	{"L", "发生过软死锁"},
+	{"K", "内核被热补丁修改"},
+	{"X", "辅助污染标记 (由发行版定义)"},
+	{"T", "内核使用 randstruct 插件构建"},
+	{"N", "加载了内核测试模块"},
+}
+
+// TaintFlag 是内核污染标记中的一位
+type TaintFlag struct {
+	Bit         int
+	Letter      string
+	Description string
+}
+
+// KernelTaint 读取 /proc/sys/kernel/tainted 并返回已设置的标记
+func KernelTaint() (uint64, []TaintFlag, error) {
+	content, err := readTrimmed("/proc/sys/kernel/tainted")
+	if err != nil {
+		return 0, nil, err
+	}
+	value, err := strconv.ParseUint(content, 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("/proc/sys/kernel/tainted 格式无效: %w", err)
+	}
+	var flags []TaintFlag
+	for bit := 0; bit < 64; bit++ {
+		if value&(1<<bit) == 0 {
+			continue
+		}
+		flag := TaintFlag{Bit: bit, Letter: "?", Description: "未知的污染标记"}
+		if bit < len(taintFlags) {
+			flag.Letter, flag.Description = taintFlags[bit].Letter, taintFlags[bit].Description
+		}
+		flags = append(flags, flag)
+	}
+	return value, flags, nil
+}
+
+// ModuleIndex 读取 /lib/modules/<release>/modules.dep 和 modules.builtin，返回随内核安装的模块名称。
+// 名称中的 '-' 统一替换为 '_'，与 /proc/modules 中的写法一致
+func ModuleIndex(release string) (map[string]string, error) {
+	dir := filepath.Join("/lib/modules", release)
+	index := make(map[string]string)
+	dep, err := os.ReadFile(filepath.Join(dir, "modules.dep"))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(dep), "\n") {
+		path, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		index[moduleNameFromPath(path)] = path
+	}
+	if builtin, err := os.ReadFile(filepath.Join(dir, "modules.builtin")); err == nil {
+		for _, path := range strings.Fields(string(builtin)) {
+			index[moduleNameFromPath(path)] = "(builtin)"
+		}
+	}
+	return index, nil
+}
+
+// KernelRelease 返回当前运行的内核版本
+func KernelRelease() (string, error) {
+	return readTrimmed("/proc/sys/kernel/osrelease")
+}
+
+// moduleNameFromPath 从模块文件路径中得到模块名，如 kernel/fs/ext4/ext4.ko.xz -> ext4
+func moduleNameFromPath(path string) string {
+	name := filepath.Base(path)
+	if i := strings.Index(name, ".ko"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func readTrimmed(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,194 @@
+package procfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SocketProtocols 是 /proc/net 下支持解析的套接字表
+var SocketProtocols = []string{"tcp", "tcp6", "udp", "udp6", "raw", "raw6"}
+
+// tcpStates 对应内核 include/net/tcp_states.h 中的状态编号
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED", "02": "SYN_SENT", "03": "SYN_RECV", "04": "FIN_WAIT1",
+	"05": "FIN_WAIT2", "06": "TIME_WAIT", "07": "CLOSE", "08": "CLOSE_WAIT",
+	"09": "LAST_ACK", "0A": "LISTEN", "0B": "CLOSING", "0C": "NEW_SYN_RECV",
+}
+
+// Socket 是从 /proc/net 读取的一条套接字记录
+type Socket struct {
+	Proto      string // tcp、tcp6、udp、udp6、raw、raw6
+	LocalAddr  netip.Addr
+	LocalPort  uint16 // raw 套接字为IP协议号
+	RemoteAddr netip.Addr
+	RemotePort uint16
+	State      string // TCP 状态；UDP 和 raw 套接字未连接时为 UNCONN，与 ss 的输出一致
+	UID        int
+	Inode      uint64
+	PID        int // 持有该套接字的进程，无法确定时为 0
+	Process    string
+	Exe        string
+}
+
+// Listening 判断套接字是否处于监听状态 (TCP LISTEN，或未连接的 UDP/raw 套接字)
+func (s Socket) Listening() bool {
+	return s.State == "LISTEN" || s.State == "UNCONN"
+}
+
+// Local 返回 "地址:端口" 形式的本地地址
+func (s Socket) Local() string {
+	return netip.AddrPortFrom(s.LocalAddr, s.LocalPort).String()
+}
+
+// Remote 返回 "地址:端口" 形式的远端地址
+func (s Socket) Remote() string {
+	return netip.AddrPortFrom(s.RemoteAddr, s.RemotePort).String()
+}
+
+// Sockets 读取 /proc/net 下的套接字表，并通过 /proc/<pid>/fd 将套接字关联到所属进程。
+// 不存在的表 (如系统禁用了IPv6) 会被忽略；其他读取失败的表通过 failed 返回，以便调用方标记结果不完整
+func Sockets(ctx context.Context) (sockets []Socket, failed []string, err error) {
+	for _, proto := range SocketProtocols {
+		path := filepath.Join("/proc/net", proto)
+		table, err := readSocketTable(path, proto)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				failed = append(failed, path)
+			}
+			continue
+		}
+		sockets = append(sockets, table...)
+	}
+	if len(failed) == len(SocketProtocols) {
+		return nil, failed, fmt.Errorf("无法读取 /proc/net 下的任何套接字表")
+	}
+
+	owners, err := socketOwners(ctx)
+	for i := range sockets {
+		if pid, ok := owners[sockets[i].Inode]; ok {
+			sockets[i].PID = pid
+			comm, _ := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+			sockets[i].Process = strings.TrimSpace(string(comm))
+			sockets[i].Exe, _ = os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe"))
+		}
+	}
+	return sockets, failed, err
+}
+
+// readSocketTable 解析单个 /proc/net/{tcp,udp,raw}[6] 文件
+func readSocketTable(path, proto string) ([]Socket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sockets []Socket
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // 跳过表头
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		local, lport, err1 := parseHexAddr(fields[1])
+		remote, rport, err2 := parseHexAddr(fields[2])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		s := Socket{
+			Proto:      proto,
+			LocalAddr:  local,
+			LocalPort:  lport,
+			RemoteAddr: remote,
+			RemotePort: rport,
+			State:      socketState(proto, fields[3]),
+		}
+		s.UID, _ = strconv.Atoi(fields[7])
+		s.Inode, _ = strconv.ParseUint(fields[9], 10, 64)
+		sockets = append(sockets, s)
+	}
+	return sockets, scanner.Err()
+}
+
+// parseHexAddr 解析 "0100007F:0050" 形式的地址。地址按32位字以主机字节序 (小端) 存储，端口为大端
+func parseHexAddr(s string) (netip.Addr, uint16, error) {
+	host, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return netip.Addr{}, 0, fmt.Errorf("地址格式无效: %s", s)
+	}
+	raw, err := hex.DecodeString(host)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return netip.Addr{}, 0, fmt.Errorf("地址格式无效: %s", s)
+	}
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(raw[i:], binary.LittleEndian.Uint32(raw[i:]))
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return netip.Addr{}, 0, fmt.Errorf("端口格式无效: %s", s)
+	}
+	return addr, uint16(p), nil
+}
+
+func socketState(proto, st string) string {
+	if !strings.HasPrefix(proto, "tcp") {
+		// UDP 和 raw 套接字只会处于 ESTABLISHED (已 connect) 或 CLOSE 状态
+		if st == "01" {
+			return "ESTABLISHED"
+		}
+		return "UNCONN"
+	}
+	if state, ok := tcpStates[st]; ok {
+		return state
+	}
+	return "UNKNOWN(" + st + ")"
+}
+
+// socketOwners 遍历所有进程的文件描述符，建立套接字 inode 到PID的映射。
+// 非root用户无法读取其他用户进程的 fd 目录，这些套接字将无法关联到进程
+func socketOwners(ctx context.Context) (map[uint64]int, error) {
+	owners := make(map[uint64]int)
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return owners, err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return owners, err
+		}
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if _, exists := owners[inode]; !exists {
+				owners[inode] = pid
+			}
+		}
+	}
+	return owners, nil
+}
@@ -0,0 +1,232 @@
+package procfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// userHZ 是 /proc/<pid>/stat 中时间字段的单位 (每秒时钟滴答数)。
+// 内核对用户态导出的 USER_HZ 在所有主流架构上固定为 100，无需通过 sysconf 查询
+const userHZ = 100
+
+// Process 是从 /proc/<pid> 读取的一条进程记录
+type Process struct {
+	PID       int
+	PPID      int
+	UID       int // 真实UID
+	Name      string
+	State     string
+	Exe       string // 可执行文件路径，已被删除的文件带有 " (deleted)" 后缀；内核线程或无权限时为空
+	Cwd       string
+	Cmdline   string // 以空格连接的命令行参数，内核线程为空
+	StartTime time.Time
+}
+
+// ExeDeleted 判断进程的可执行文件是否已从磁盘上删除
+func (p Process) ExeDeleted() bool {
+	return strings.HasSuffix(p.Exe, " (deleted)")
+}
+
+// Processes 读取 /proc 下的全部进程。ctx 结束时返回已读取的部分进程及 ctx 的错误；
+// 读取期间退出的进程会被忽略
+func Processes(ctx context.Context) ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 /proc: %w", err)
+	}
+	bootTime, _ := BootTime()
+
+	var procs []Process
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return procs, err
+		}
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		p, err := readProcess(pid, bootTime)
+		if err != nil {
+			continue
+		}
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs, nil
+}
+
+// ReadProcess 读取单个进程的信息
+func ReadProcess(pid int) (Process, error) {
+	bootTime, _ := BootTime()
+	return readProcess(pid, bootTime)
+}
+
+func readProcess(pid int, bootTime time.Time) (Process, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	p := Process{PID: pid}
+
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return p, err
+	}
+	if err := parseStat(&p, string(stat), bootTime); err != nil {
+		return p, fmt.Errorf("解析 %s/stat 失败: %w", dir, err)
+	}
+	if status, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		p.UID = parseStatusUID(string(status))
+	}
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		p.Cmdline = strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+	}
+	p.Exe, _ = os.Readlink(filepath.Join(dir, "exe"))
+	p.Cwd, _ = os.Readlink(filepath.Join(dir, "cwd"))
+	return p, nil
+}
+
+// parseStat 解析 /proc/<pid>/stat。进程名位于括号中且可能包含空格和括号，因此以最后一个 ')' 为界
+func parseStat(p *Process, stat string, bootTime time.Time) error {
+	open := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return fmt.Errorf("格式无效")
+	}
+	p.Name = stat[open+1 : end]
+	// 括号之后的字段从 state (第3个字段) 开始
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return fmt.Errorf("字段数不足")
+	}
+	p.State = fields[0]
+	p.PPID, _ = strconv.Atoi(fields[1])
+	if ticks, err := strconv.ParseUint(fields[19], 10, 64); err == nil && !bootTime.IsZero() {
+		p.StartTime = bootTime.Add(time.Duration(ticks) * time.Second / userHZ)
+	}
+	return nil
+}
+
+// parseStatusUID 从 /proc/<pid>/status 中取出真实UID
+func parseStatusUID(status string) int {
+	for _, line := range strings.Split(status, "\n") {
+		if strings.HasPrefix(line, "Uid:") {
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				uid, _ := strconv.Atoi(fields[1])
+				return uid
+			}
+		}
+	}
+	return -1
+}
+
+// BootTime 从 /proc/stat 读取系统启动时间
+func BootTime() (time.Time, error) {
+	content, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			sec, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("/proc/stat 中缺少 btime")
+}
+
+// PIDs 返回读取 /proc 目录时列出的全部进程号。内核级 rootkit 通常通过拦截该目录的遍历来隐藏进程
+func PIDs() (map[int]bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 /proc: %w", err)
+	}
+	pids := make(map[int]bool, len(entries))
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil {
+			pids[pid] = true
+		}
+	}
+	return pids, nil
+}
+
+// Threads 返回进程 /proc/<pid>/task 下的全部线程号
+func Threads(pid int) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, err
+	}
+	var tids []int
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+// Tgid 返回线程所属的进程号 (线程组ID)
+func Tgid(tid int) (int, error) {
+	status, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(tid), "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if strings.HasPrefix(line, "Tgid:") {
+			return strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Tgid:")))
+		}
+	}
+	return 0, fmt.Errorf("/proc/%d/status 中缺少 Tgid", tid)
+}
+
+// PIDMax 返回系统允许的最大进程号
+func PIDMax() (int, error) {
+	content, err := os.ReadFile("/proc/sys/kernel/pid_max")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
+
+// ProbePIDs 逐个探测 1 到 max 之间的 /proc/<pid> 是否存在，返回存在的进程号及线程号。
+// 即使进程在目录遍历中被隐藏，直接访问其路径通常仍然可以成功
+func ProbePIDs(ctx context.Context, max int) ([]int, error) {
+	var found []int
+	for pid := 1; pid <= max; pid++ {
+		if pid%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return found, err
+			}
+		}
+		if _, err := os.Lstat("/proc/" + strconv.Itoa(pid)); err == nil {
+			found = append(found, pid)
+		}
+	}
+	return found, nil
+}
+
+// LoadavgEntities 返回 /proc/loadavg 中记录的内核调度实体 (线程) 总数
+func LoadavgEntities() (int, error) {
+	content, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	// 格式: 0.00 0.01 0.05 1/123 4567
+	fields := strings.Fields(string(content))
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("/proc/loadavg 格式无效")
+	}
+	_, total, ok := strings.Cut(fields[3], "/")
+	if !ok {
+		return 0, fmt.Errorf("/proc/loadavg 格式无效")
+	}
+	return strconv.Atoi(total)
+}
@@ -31,15 +31,63 @@ func (g MarkdownGenerator) Generate(data types.ReportData, outputDir string) err
 - **生成工具:** {{.GeneratedBy}}
 - **总检查项:** {{.TotalChecks}}
 - **发现可疑项:** {{.SuspiciousCount}}
+- **检查失败项:** {{.ErrorCount}}
+- **结果不完整项:** {{.DegradedCount}}
+- **超时检查项:** {{.TimedOutCount}}
+- **跳过检查项:** {{.SkippedCount}}
+- **已抑制发现:** {{.SuppressedCount}}
+- **主机风险评分:** {{.Score.Score}}/100 ({{.Score.Verdict.Label}})
+{{- if .Score.Contributions}}
+
+### 风险评分构成
+
+评分由各发现按风险等级和检查项分类加权累计而成 (上限 100)，同名发现重复出现时得分逐条递减，被抑制的发现不计分。
+
+| 发现 | 检查项 | 风险 | 数量 | 得分 |
+| :--- | :--- | :--- | :--- | :--- |
+{{- range .Score.Contributions}}
+| {{.Name}} | {{.CheckName}}{{if .Container}} [容器: {{.Container}}]{{end}} | {{.RiskLevel}} | {{.Count}} | {{.Points}} |
+{{- end}}
+{{- end}}
+{{- if .ExpiredSuppressions}}
+
+### 已过期的抑制规则 ({{len .ExpiredSuppressions}})
+
+以下抑制规则已过期，不再生效，相关发现会重新出现在报告中。请负责人确认后续期或删除。
+
+| 匹配条件 | 理由 | 负责人 | 失效日期 |
+| :--- | :--- | :--- | :--- |
+{{- range .ExpiredSuppressions}}
+| {{.String}} | {{.Reason}} | {{.Owner}} | {{.Expires}} |
+{{- end}}
+{{- end}}
+{{- if .AttackView}}
+
+### ATT&CK 战术视图
+
+以下按 MITRE ATT&CK 矩阵的战术顺序列出风险发现，关联多个战术的发现会在每个战术下各出现一次。{{if .UnmappedFindings}}另有 {{.UnmappedFindings}} 条发现未关联 ATT&CK 战术或技术。{{end}}
+{{range .AttackView}}
+#### {{.Label}}{{if .ID}} ({{.ID}} {{.Name}}){{end}} - {{len .Findings}} 条发现
+
+| 技术 | 发现 | 检查项 | 风险 |
+| :--- | :--- | :--- | :--- |
+{{- range .Findings}}
+| {{range $i, $t := .Attack.Techniques}}{{if $i}}, {{end}}{{$t}}{{else}}-{{end}} | {{.Name}} | {{.CheckName}}{{if .Container}} [容器: {{.Container}}]{{end}} | {{.RiskLevel}} |
+{{- end}}
+{{end}}
+{{- end}}
 
 ---
 
 ## 2. 详细检测结果
 
 {{range .Checks}}
-### {{.Category}} - {{.Description}}
+### {{.Category}} - {{.Description}}{{with .Container}} [容器: {{.Name}}]{{end}}
 
-- **结果:** {{if .IsSuspicious}}**[可疑]**{{else}}[正常]{{end}} {{.Result}}
+- **结果:** {{.Status.Label}} {{.Result}}
+{{- with .Container}}
+- **容器:** {{.Name}} (ID: {{.ShortID}}, 镜像: {{.Image}}, 运行时: {{.Runtime}})
+{{- end}}
 
 <details>
 <summary>点击展开/折叠详细信息</summary>
@@ -54,10 +102,25 @@ func (g MarkdownGenerator) Generate(data types.ReportData, outputDir string) err
 [{{.Source}} | 规则: {{.Name}}] [风险: {{.RiskLevel}}]
 说明: {{.Description}}
 匹配内容: {{.MatchedLine}}
----
+{{if .Field}}匹配字段: {{.Field}}={{index .Record .Field}}
+{{end}}{{with .File}}文件哈希: {{.}}
+{{end}}{{with .Attack}}ATT&CK: {{.}}
+{{end}}---
 {{end}}
 ` + "```" + `
 {{end}}
+{{- if .Suppressed}}
+
+#### 已抑制的发现 ({{len .Suppressed}})
+` + "```" + `
+{{range .Suppressed}}
+[{{.Source}} | 规则: {{.Name}}] [风险: {{.RiskLevel}}]
+匹配内容: {{.MatchedLine}}
+抑制理由: {{.Reason}} (负责人: {{.Owner}}, 有效期至: {{.Expires}})
+---
+{{end}}
+` + "```" + `
+{{- end}}
 
 #### 原始数据
 ` + "```" + `
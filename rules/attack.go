@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Attack 描述规则、IOC 或风险发现对应的 MITRE ATT&CK 战术和技术
+type Attack struct {
+	Tactics    []string `yaml:"tactics,omitempty" json:",omitempty"`    // 战术名称 (如 persistence) 或编号 (如 TA0003)
+	Techniques []string `yaml:"techniques,omitempty" json:",omitempty"` // 技术或子技术编号，如 T1053、T1053.003
+}
+
+// Tactic 是 ATT&CK Enterprise 矩阵中的一个战术
+type Tactic struct {
+	ID    string
+	Name  string
+	Label string // 报告中展示的中文名称
+}
+
+// Tactics 按 ATT&CK 矩阵中的顺序列出所有战术
+var Tactics = []Tactic{
+	{"TA0043", "reconnaissance", "侦察"},
+	{"TA0042", "resource-development", "资源开发"},
+	{"TA0001", "initial-access", "初始访问"},
+	{"TA0002", "execution", "执行"},
+	{"TA0003", "persistence", "持久化"},
+	{"TA0004", "privilege-escalation", "权限提升"},
+	{"TA0005", "defense-evasion", "防御规避"},
+	{"TA0006", "credential-access", "凭据访问"},
+	{"TA0007", "discovery", "发现"},
+	{"TA0008", "lateral-movement", "横向移动"},
+	{"TA0009", "collection", "收集"},
+	{"TA0011", "command-and-control", "命令与控制"},
+	{"TA0010", "exfiltration", "数据渗出"},
+	{"TA0040", "impact", "影响"},
+}
+
+// techniqueRe 匹配 ATT&CK 技术编号及子技术编号
+var techniqueRe = regexp.MustCompile(`^T[0-9]{4}(\.[0-9]{3})?$`)
+
+// LookupTactic 按名称或编号查找战术，名称不区分大小写，空格和下划线视同连字符
+func LookupTactic(s string) (Tactic, bool) {
+	key := strings.ToLower(strings.NewReplacer(" ", "-", "_", "-").Replace(strings.TrimSpace(s)))
+	for _, t := range Tactics {
+		if key == t.Name || strings.EqualFold(key, t.ID) {
+			return t, true
+		}
+	}
+	return Tactic{}, false
+}
+
+// IsZero 判断是否未设置任何战术和技术
+func (a Attack) IsZero() bool {
+	return len(a.Tactics) == 0 && len(a.Techniques) == 0
+}
+
+// Normalize 校验战术和技术并统一为战术名称和大写的技术编号，返回规范化后的结果
+func (a Attack) Normalize() (Attack, error) {
+	var n Attack
+	for _, s := range a.Tactics {
+		t, ok := LookupTactic(s)
+		if !ok {
+			return Attack{}, fmt.Errorf("未知的 ATT&CK 战术 '%s'", s)
+		}
+		n.Tactics = append(n.Tactics, t.Name)
+	}
+	for _, s := range a.Techniques {
+		id := strings.ToUpper(strings.TrimSpace(s))
+		if !techniqueRe.MatchString(id) {
+			return Attack{}, fmt.Errorf("ATT&CK 技术编号 '%s' 格式无效，应为 T1234 或 T1234.001", s)
+		}
+		n.Techniques = append(n.Techniques, id)
+	}
+	return n, nil
+}
+
+// String 返回如 "persistence / T1053.003" 形式的摘要
+func (a Attack) String() string {
+	return strings.Join(append(append([]string{}, a.Tactics...), a.Techniques...), " / ")
+}
+
+// attackPtr 返回可赋给 Finding.Attack 的指针，未设置时返回 nil
+func attackPtr(a Attack) *Attack {
+	if a.IsZero() {
+		return nil
+	}
+	return &a
+}
+
+// UnknownTactic 用于归类只声明了技术而未声明战术的规则和发现
+var UnknownTactic = Tactic{Label: "未指定战术"}
@@ -0,0 +1,549 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition 是编译后的规则条件表达式。
+//
+// 语法示例:
+//
+//	exe startswith '/tmp/' and not user == 'jenkins'
+//	count > 10 and entity not in ['10.0.0.1', '10.0.0.2']
+//	lower(basename(exe)) in ['sh', 'bash'] or cmdline matches 'nc\s+-e'
+//
+// 支持的运算:
+//   - 逻辑: and、or、not (也可写作 &&、||、!)，可使用括号分组
+//   - 比较: ==、!=、>、>=、<、<=，两侧均为数字时按数值比较，否则按字符串比较
+//   - 字符串: contains、startswith (starts with)、endswith (ends with)、matches (正则)，均可用 not 取反
+//   - 集合: in [...]、not in [...]
+//   - 函数: lower、upper、trim、len、basename、dirname
+//
+// 标识符表示字段，不存在的字段取值为空字符串
+type Condition struct {
+	src  string
+	root condNode
+}
+
+// CompileCondition 编译条件表达式
+func CompileCondition(src string) (*Condition, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	return compileTokens(src, tokens)
+}
+
+// compileTokens 对词法分析的结果进行语法分析，src 为条件表达式的原文
+func compileTokens(src string, tokens []token) (*Condition, error) {
+	p := &condParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("条件表达式在 '%s' 处有多余的内容", p.peek().text)
+	}
+	return &Condition{src: src, root: root}, nil
+}
+
+// Eval 使用给定字段对条件求值
+func (c *Condition) Eval(fields map[string]string) bool {
+	return truthy(c.root.eval(fields))
+}
+
+// String 返回条件表达式的原文
+func (c *Condition) String() string { return c.src }
+
+// Fields 返回条件表达式引用的全部字段名，按字母顺序排列且不重复
+func (c *Condition) Fields() []string {
+	seen := make(map[string]bool)
+	var walk func(n condNode)
+	walk = func(n condNode) {
+		switch n := n.(type) {
+		case fieldNode:
+			seen[n.name] = true
+		case listNode:
+			for _, item := range n.items {
+				walk(item)
+			}
+		case notNode:
+			walk(n.operand)
+		case andNode:
+			walk(n.left)
+			walk(n.right)
+		case orNode:
+			walk(n.left)
+			walk(n.right)
+		case callNode:
+			walk(n.arg)
+		case compareNode:
+			walk(n.left)
+			walk(n.right)
+		}
+	}
+	walk(c.root)
+	fields := make([]string, 0, len(seen))
+	for name := range seen {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// fieldRe 匹配行内 "key=value" 形式的字段名
+var fieldRe = regexp.MustCompile(`(?:^|\s)([A-Za-z_][A-Za-z0-9_.]*)=`)
+
+// ParseFields 从一行 "key=value key2=value2" 形式的文本中提取字段。每个值延续到下一个字段名之前，
+// 因此含空格的值 (如 cmdline) 应放在行尾。整行文本始终可以通过 line 字段访问
+func ParseFields(line string) map[string]string {
+	fields := map[string]string{"line": line}
+	matches := fieldRe.FindAllStringSubmatchIndex(line, -1)
+	for i, m := range matches {
+		end := len(line)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		fields[line[m[2]:m[3]]] = strings.TrimSpace(line[m[1]:end])
+	}
+	return fields
+}
+
+// --- 词法分析 ---
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokOp
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	r := []rune(src)
+	for i := 0; i < len(r); {
+		ch := r[i]
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '\'' || ch == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(r) && r[j] != ch; j++ {
+				if r[j] == '\\' && j+1 < len(r) && (r[j+1] == ch || r[j+1] == '\\') {
+					j++
+				}
+				sb.WriteRune(r[j])
+			}
+			if j >= len(r) {
+				return nil, fmt.Errorf("字符串未闭合: %s", string(r[i:]))
+			}
+			tokens = append(tokens, token{tokString, sb.String()})
+			i = j + 1
+		case unicode.IsDigit(ch) || (ch == '-' && i+1 < len(r) && unicode.IsDigit(r[i+1])):
+			j := i + 1
+			for j < len(r) && (unicode.IsDigit(r[j]) || r[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, string(r[i:j])})
+			i = j
+		case unicode.IsLetter(ch) || ch == '_':
+			j := i + 1
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_' || r[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, string(r[i:j])})
+			i = j
+		default:
+			if i+1 < len(r) {
+				if two := string(r[i : i+2]); two == "==" || two == "!=" || two == ">=" || two == "<=" || two == "&&" || two == "||" {
+					tokens = append(tokens, token{tokOp, two})
+					i += 2
+					continue
+				}
+			}
+			if strings.ContainsRune("()[],<>!", ch) {
+				tokens = append(tokens, token{tokOp, string(ch)})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("无法识别的字符 '%c'", ch)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// --- 语法分析 ---
+
+type condParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *condParser) peek() token { return p.tokens[p.pos] }
+func (p *condParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+func (p *condParser) done() bool { return p.peek().kind == tokEOF }
+
+// isKeyword 判断当前词是否为指定关键字 (不区分大小写)
+func (p *condParser) isKeyword(words ...string) bool {
+	return p.peek().is(words...)
+}
+
+// is 判断词是否为指定关键字或运算符 (不区分大小写)
+func (t token) is(words ...string) bool {
+	if t.kind != tokIdent && t.kind != tokOp {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *condParser) parseOr() (condNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseAnd() (condNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and", "&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseUnary() (condNode, error) {
+	if p.isKeyword("not", "!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+var comparisonOps = []string{"==", "!=", ">=", "<=", ">", "<", "contains", "startswith", "endswith", "matches", "in", "starts", "ends"}
+
+func (p *condParser) parseComparison() (condNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	negate := false
+	if p.isKeyword("not") {
+		// "x not in [...]"、"x not contains y" 等形式
+		save := p.pos
+		p.next()
+		if !p.isKeyword(comparisonOps...) {
+			p.pos = save
+			return left, nil
+		}
+		negate = true
+	}
+	if !p.isKeyword(comparisonOps...) {
+		return left, nil
+	}
+	op := strings.ToLower(p.next().text)
+	if op == "starts" || op == "ends" {
+		if !p.isKeyword("with") {
+			return nil, fmt.Errorf("'%s' 之后应为 'with'", op)
+		}
+		p.next()
+		op += "with"
+	}
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	node := compareNode{op: op, left: left, right: right}
+	if op == "matches" {
+		if lit, ok := right.(literalNode); ok {
+			re, err := regexp.Compile(toString(lit.value))
+			if err != nil {
+				return nil, fmt.Errorf("matches 的正则表达式无效: %w", err)
+			}
+			node.re = re
+		}
+	}
+	if negate {
+		return notNode{node}, nil
+	}
+	return node, nil
+}
+
+func (p *condParser) parsePrimary() (condNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literalNode{t.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的数字 '%s'", t.text)
+		}
+		return literalNode{n}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		}
+		if p.isKeyword("(") {
+			return p.parseCall(t.text)
+		}
+		return fieldNode{t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.next().text != ")" {
+				return nil, fmt.Errorf("缺少 ')'")
+			}
+			return inner, nil
+		case "[":
+			var items []condNode
+			for !p.isKeyword("]") {
+				item, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if p.isKeyword(",") {
+					p.next()
+				} else if !p.isKeyword("]") {
+					return nil, fmt.Errorf("列表中缺少 ',' 或 ']'")
+				}
+			}
+			p.next()
+			return listNode{items}, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("条件表达式不完整")
+	}
+	return nil, fmt.Errorf("意外的 '%s'", t.text)
+}
+
+func (p *condParser) parseCall(name string) (condNode, error) {
+	fn, ok := condFuncs[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("未知的函数 '%s'", name)
+	}
+	p.next() // (
+	arg, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next().text != ")" {
+		return nil, fmt.Errorf("函数 '%s' 只接受一个参数，缺少 ')'", name)
+	}
+	return callNode{fn: fn, arg: arg}, nil
+}
+
+var condFuncs = map[string]func(string) interface{}{
+	"lower":    func(s string) interface{} { return strings.ToLower(s) },
+	"upper":    func(s string) interface{} { return strings.ToUpper(s) },
+	"trim":     func(s string) interface{} { return strings.TrimSpace(s) },
+	"len":      func(s string) interface{} { return float64(len([]rune(s))) },
+	"basename": func(s string) interface{} { return path.Base(s) },
+	"dirname":  func(s string) interface{} { return path.Dir(s) },
+}
+
+// --- 求值 ---
+
+type condNode interface {
+	eval(fields map[string]string) interface{}
+}
+
+type literalNode struct{ value interface{} }
+type fieldNode struct{ name string }
+type listNode struct{ items []condNode }
+type notNode struct{ operand condNode }
+type andNode struct{ left, right condNode }
+type orNode struct{ left, right condNode }
+type callNode struct {
+	fn  func(string) interface{}
+	arg condNode
+}
+type compareNode struct {
+	op          string
+	left, right condNode
+	re          *regexp.Regexp // matches 右侧为字面量时预编译
+}
+
+func (n literalNode) eval(map[string]string) interface{} { return n.value }
+func (n fieldNode) eval(f map[string]string) interface{} { return f[n.name] }
+func (n notNode) eval(f map[string]string) interface{}   { return !truthy(n.operand.eval(f)) }
+func (n andNode) eval(f map[string]string) interface{} {
+	return truthy(n.left.eval(f)) && truthy(n.right.eval(f))
+}
+func (n orNode) eval(f map[string]string) interface{} {
+	return truthy(n.left.eval(f)) || truthy(n.right.eval(f))
+}
+func (n callNode) eval(f map[string]string) interface{} { return n.fn(toString(n.arg.eval(f))) }
+func (n listNode) eval(f map[string]string) interface{} {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(f)
+	}
+	return values
+}
+
+func (n compareNode) eval(f map[string]string) interface{} {
+	left, right := n.left.eval(f), n.right.eval(f)
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case ">", ">=", "<", "<=":
+		c := compare(left, right)
+		switch n.op {
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		case "<":
+			return c < 0
+		}
+		return c <= 0
+	case "contains":
+		return strings.Contains(toString(left), toString(right))
+	case "startswith":
+		return strings.HasPrefix(toString(left), toString(right))
+	case "endswith":
+		return strings.HasSuffix(toString(left), toString(right))
+	case "matches":
+		re := n.re
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(toString(right)); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(toString(left))
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			return equal(left, right)
+		}
+		for _, item := range list {
+			if equal(left, item) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	case []interface{}:
+		return len(x) > 0
+	}
+	return false
+}
+
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// toNumber 尝试将值转换为数字，字段值以字符串形式存储
+func toNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	return toString(a) == toString(b)
+}
+
+func compare(a, b interface{}) int {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
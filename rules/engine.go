@@ -3,10 +3,11 @@ package rules
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path"
 	"path/filepath"
 	"regexp"
-	"strconv"
-	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,11 +19,35 @@ type Rule struct {
 	Description         string           `yaml:"description"`
 	TargetCheck         string           `yaml:"target_check"`
 	Type                string           `yaml:"type"`
-	Patterns            []string         `yaml:"patterns"`
-	Pattern             string           `yaml:"pattern"`
-	Condition           string           `yaml:"condition"`
+	Field               string           `yaml:"field,omitempty"`
+	Patterns            []string         `yaml:"patterns,omitempty"`
+	Pattern             string           `yaml:"pattern,omitempty"`
+	Condition           string           `yaml:"condition,omitempty"`
+	Window              string           `yaml:"window,omitempty"`      // agg_regex: 滑动时间窗口，如 5m、1h，为空表示统计全部记录
+	Distinct            string           `yaml:"distinct,omitempty"`    // agg_regex: 统计同一实体在该字段上的不同取值数
+	TimeField           string           `yaml:"time_field,omitempty"`  // agg_regex: 事件时间所在的字段，默认为 time
+	TimeFormat          string           `yaml:"time_format,omitempty"` // agg_regex: 事件时间的格式 (Go 时间布局)，为空时自动识别
 	RiskLevel           string           `yaml:"risk_level"`
+	Attack              Attack           `yaml:"attack,omitempty"`
+	Tests               Tests            `yaml:"tests,omitempty"` // 内嵌测试用例，由 -validate-rules 执行
 	precompiledPatterns []*regexp.Regexp `yaml:"-"`
+	condition           *Condition       `yaml:"-"`
+	window              time.Duration    `yaml:"-"`
+}
+
+// accepts 判断记录是否满足规则的附加条件，未设置条件时总是满足
+func (r Rule) accepts(rec Record) bool {
+	return r.condition == nil || r.condition.Eval(rec)
+}
+
+// target 返回规则要匹配的内容：指定了 field 时为该字段的值，否则为记录的文本形式。
+// 记录中不存在指定字段时返回 false
+func (r Rule) target(rec Record) (string, bool) {
+	if r.Field == "" {
+		return rec.Line(), true
+	}
+	value, ok := rec[r.Field]
+	return value, ok
 }
 
 // RuleFile 定义了规则文件的结构
@@ -38,6 +63,11 @@ type IOC struct {
 	Description           string           `yaml:"description"`
 	MatchType             string           `yaml:"match_type"`
 	Indicators            []string         `yaml:"indicators"`
+	Attack                Attack           `yaml:"attack,omitempty"`
+	Source                string           `yaml:"source,omitempty"`      // 情报来源，如 STIX 情报的生产者或 MISP 事件的组织
+	Confidence            int              `yaml:"confidence,omitempty"`  // 置信度 (1-100)，0 表示未知
+	ValidUntil            string           `yaml:"valid_until,omitempty"` // 失效时间 (RFC 3339 或 YYYY-MM-DD)，过期的情报不会加载
+	Tests                 Tests            `yaml:"tests,omitempty"`       // 内嵌测试用例，由 -validate-rules 执行
 	precompiledIndicators []*regexp.Regexp `yaml:"-"`
 }
 
@@ -50,7 +80,17 @@ type IOCFile struct {
 type RuleEngine struct {
 	rulesByCheck map[string][]Rule
 	iocsByType   map[string][]IOC
+	ips          ipSet             // 非正则匹配的 IP 类型 IOC，按网段索引
+	hashes       map[string][]*IOC // 文件哈希类型的 IOC，以小写十六进制哈希值为键
 	yaraCompiler interface{}
+
+	// 关键词规则和关键词 IOC 编译成的自动机，由 compileKeywords 在加载完成后构建
+	keywordIndexes map[string][]*keywordIndex
+	iocIndexes     map[string]*iocIndex
+
+	hostname            string // 当前检查的主机名，用于匹配抑制规则中的 host
+	suppressions        []Suppression
+	expiredSuppressions []Suppression
 }
 
 // Finding 代表一个由规则或IOC匹配产生的风险发现
@@ -60,14 +100,24 @@ type Finding struct {
 	Description string
 	RiskLevel   string
 	MatchedLine string
+	Field       string      `json:",omitempty"` // 规则匹配的字段，为空表示匹配整条记录
+	Record      Record      `json:",omitempty"` // 触发匹配的完整记录
+	Attack      *Attack     `json:",omitempty"` // 对应的 ATT&CK 战术和技术
+	File        *FileHashes `json:",omitempty"` // 所涉及文件的哈希值
 }
 
-// NewRuleEngine 创建并初始化一个新的规则引擎
-func NewRuleEngine(rulesDir string, iocPath string) (*RuleEngine, error) {
-	engine := &RuleEngine{
+// newEngine 创建一个未加载任何规则和 IOC 的规则引擎
+func newEngine() *RuleEngine {
+	return &RuleEngine{
 		rulesByCheck: make(map[string][]Rule),
 		iocsByType:   make(map[string][]IOC),
+		hashes:       make(map[string][]*IOC),
 	}
+}
+
+// NewRuleEngine 创建并初始化一个新的规则引擎
+func NewRuleEngine(rulesDir string, iocPath string) (*RuleEngine, error) {
+	engine := newEngine()
 
 	// 调用 initYara, Go会根据构建标签自动选择正确的版本
 	// 这个函数在 yara_enabled.go 或 yara_disabled.go 中定义
@@ -96,35 +146,27 @@ func NewRuleEngine(rulesDir string, iocPath string) (*RuleEngine, error) {
 			}
 
 			for i := range ruleFile.Rules {
-				rule := &ruleFile.Rules[i]
-				if !rule.Enabled {
-					continue
-				}
-
-				// 规则预编译
-				if rule.Type == "regex" {
-					for _, p := range rule.Patterns {
-						re, err := regexp.Compile(p)
-						if err != nil {
-							fmt.Printf("警告: 编译规则 '%s' 的正则表达式 '%s' 失败, 已跳过: %v\n", rule.Name, p, err)
-							continue
-						}
-						rule.precompiledPatterns = append(rule.precompiledPatterns, re)
-					}
-				} else if rule.Type == "agg_regex" {
-					re, err := regexp.Compile(rule.Pattern)
-					if err != nil {
-						fmt.Printf("警告: 编译规则 '%s' 的正则表达式 '%s' 失败, 已跳过: %v\n", rule.Name, rule.Pattern, err)
-						continue
-					}
-					rule.precompiledPatterns = []*regexp.Regexp{re}
-				}
-				// 规则分组
-				engine.rulesByCheck[rule.TargetCheck] = append(engine.rulesByCheck[rule.TargetCheck], *rule)
+				engine.addRule(ruleFile.Rules[i])
 			}
 		}
 	}
 
+	// --- 加载 Sigma 规则 ---
+	// rules/sigma 目录中的 Sigma 规则在加载时转换，无法转换的规则会被跳过
+	sigmaDir := filepath.Join(rulesDir, "sigma")
+	if info, err := os.Stat(sigmaDir); err == nil && info.IsDir() {
+		sigmaRules, issues, err := LoadSigma(sigmaDir)
+		if err != nil {
+			fmt.Printf("警告: 无法读取 Sigma 规则目录 '%s', 已跳过: %v\n", sigmaDir, err)
+		}
+		for _, issue := range issues {
+			fmt.Printf("警告: 无法转换 Sigma 规则, 已跳过: %s\n", issue)
+		}
+		for _, rule := range sigmaRules {
+			engine.addRule(rule)
+		}
+	}
+
 	// --- 加载 IOC 文件 ---
 	iocFileContent, err := ioutil.ReadFile(iocPath)
 	if err != nil {
@@ -135,157 +177,209 @@ func NewRuleEngine(rulesDir string, iocPath string) (*RuleEngine, error) {
 		if err != nil {
 			fmt.Printf("警告: 无法解析威胁情报文件 '%s', IOC功能将不可用: %v\n", iocPath, err)
 		} else {
-			for i := range iocFile.IOCs {
-				ioc := &iocFile.IOCs[i]
-				if !ioc.Enabled {
-					continue
-				}
-				if ioc.MatchType == "regex" {
-					for _, indicator := range ioc.Indicators {
-						re, err := regexp.Compile(indicator)
-						if err != nil {
-							fmt.Printf("警告: 编译IOC '%s' 的正则表达式 '%s' 失败, 已跳过: %v\n", ioc.Name, indicator, err)
-							continue
-						}
-						ioc.precompiledIndicators = append(ioc.precompiledIndicators, re)
-					}
-				}
-				engine.iocsByType[ioc.Type] = append(engine.iocsByType[ioc.Type], *ioc)
+			now := time.Now()
+			for _, ioc := range iocFile.IOCs {
+				engine.addIOC(ioc, now)
 			}
 		}
 	}
+	engine.compileKeywords()
 
 	return engine, nil
 }
 
-// MatchIOC 对给定的文本内容执行IOC匹配
+// addRule 预编译规则并按目标检查项分组，未启用或无法编译的规则会被跳过
+func (e *RuleEngine) addRule(rule Rule) {
+	if !rule.Enabled {
+		return
+	}
+
+	// 规则预编译
+	if rule.Type == "regex" {
+		for _, p := range rule.Patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				fmt.Printf("警告: 编译规则 '%s' 的正则表达式 '%s' 失败, 已跳过: %v\n", rule.Name, p, err)
+				continue
+			}
+			rule.precompiledPatterns = append(rule.precompiledPatterns, re)
+		}
+	} else if rule.Type == "agg_regex" {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			fmt.Printf("警告: 编译规则 '%s' 的正则表达式 '%s' 失败, 已跳过: %v\n", rule.Name, rule.Pattern, err)
+			return
+		}
+		rule.precompiledPatterns = []*regexp.Regexp{re}
+		cond, clauses, err := CompileAggCondition(rule.Condition)
+		if err != nil {
+			fmt.Printf("警告: 编译规则 '%s' 的条件表达式 '%s' 失败, 已跳过: %v\n", rule.Name, rule.Condition, err)
+			return
+		}
+		if err := rule.ApplyAggClauses(clauses); err != nil {
+			fmt.Printf("警告: 规则 '%s' 的条件表达式 '%s' 无效, 已跳过: %v\n", rule.Name, rule.Condition, err)
+			return
+		}
+		rule.condition = cond
+		if rule.Window != "" {
+			window, err := time.ParseDuration(rule.Window)
+			if err != nil || window <= 0 {
+				fmt.Printf("警告: 规则 '%s' 的时间窗口 '%s' 无效, 已跳过\n", rule.Name, rule.Window)
+				return
+			}
+			rule.window = window
+		}
+	}
+	if rule.Condition != "" && rule.Type != "agg_regex" { // agg_regex 的条件表达式已随聚合子句一起编译
+		cond, err := CompileCondition(rule.Condition)
+		if err != nil {
+			fmt.Printf("警告: 编译规则 '%s' 的条件表达式 '%s' 失败, 已跳过: %v\n", rule.Name, rule.Condition, err)
+			return
+		}
+		rule.condition = cond
+	} else if rule.Type == "condition" {
+		fmt.Printf("警告: 规则 '%s' 的类型为 condition 但未设置 condition 字段, 已跳过\n", rule.Name)
+		return
+	}
+	if attack, err := rule.Attack.Normalize(); err != nil {
+		fmt.Printf("警告: 规则 '%s' 的 ATT&CK 映射无效, 已忽略: %v\n", rule.Name, err)
+		rule.Attack = Attack{}
+	} else {
+		rule.Attack = attack
+	}
+	// 规则分组
+	e.rulesByCheck[rule.TargetCheck] = append(e.rulesByCheck[rule.TargetCheck], rule)
+}
+
+// MatchIOC 对给定的文本内容执行IOC匹配。关键词 IOC 由自动机一次扫描得到全部命中，
+// 结果与正则和精确匹配的 IOC 一起按 IOC 和指标的定义顺序输出
 func (e *RuleEngine) MatchIOC(iocType string, content string) []Finding {
 	var findings []Finding
-	iocs, ok := e.iocsByType[iocType]
+	if iocType == "ip" {
+		findings = e.matchIP(content)
+	}
+	iocs := e.iocsByType[iocType]
+	index, ok := e.iocIndexes[iocType]
 	if !ok {
 		return findings
 	}
 
-	for _, ioc := range iocs {
+	hits := index.ac.scan(content)
+	h := 0
+	keywordsBefore := func(limit int) {
+		for ; h < len(hits) && index.hits[hits[h]][0] < limit; h++ {
+			pos := index.hits[hits[h]]
+			ioc, indicator := iocs[pos[0]], iocs[pos[0]].Indicators[pos[1]]
+			findings = append(findings, Finding{
+				Source:      "IOC",
+				Name:        ioc.Name,
+				Description: ioc.describe(),
+				RiskLevel:   "High",
+				MatchedLine: fmt.Sprintf("匹配到关键词指标 '%s' -> %s", indicator, content),
+				Attack:      attackPtr(ioc.Attack),
+			})
+		}
+	}
+	for _, i := range index.others {
+		keywordsBefore(i)
+		ioc := iocs[i]
 		if ioc.MatchType == "regex" {
 			for _, re := range ioc.precompiledIndicators {
 				if re.MatchString(content) {
 					findings = append(findings, Finding{
 						Source:      "IOC",
 						Name:        ioc.Name,
-						Description: ioc.Description,
+						Description: ioc.describe(),
 						RiskLevel:   "High", // IOC匹配通常风险较高
 						MatchedLine: fmt.Sprintf("匹配到正则指标 '%s' -> %s", re.String(), content),
+						Attack:      attackPtr(ioc.Attack),
 					})
 				}
 			}
-		} else { // 默认为 keyword
+		} else {
 			for _, indicator := range ioc.Indicators {
-				if strings.Contains(content, indicator) {
-					findings = append(findings, Finding{
-						Source:      "IOC",
-						Name:        ioc.Name,
-						Description: ioc.Description,
-						RiskLevel:   "High",
-						MatchedLine: fmt.Sprintf("匹配到关键词指标 '%s' -> %s", indicator, content),
-					})
+				matchedLine := fmt.Sprintf("匹配到指标 '%s'", indicator)
+				if content != indicator {
+					// 文件名指标 (如从 STIX/MISP 导入的 file:name) 不含目录，与路径的最后一段比较
+					if iocType != "filename" || path.Base(content) != indicator {
+						continue
+					}
+					matchedLine = fmt.Sprintf("匹配到文件名指标 '%s' -> %s", indicator, content)
 				}
+				findings = append(findings, Finding{
+					Source:      "IOC",
+					Name:        ioc.Name,
+					Description: ioc.describe(),
+					RiskLevel:   "High",
+					MatchedLine: matchedLine,
+					Attack:      attackPtr(ioc.Attack),
+				})
 			}
 		}
 	}
+	keywordsBefore(len(iocs))
 	return findings
 }
 
-// Match 对给定的文本内容执行匹配
+// Match 将文本按行转换为记录后执行匹配，供尚未输出结构化记录的检查项使用
 func (e *RuleEngine) Match(checkName string, content string) []Finding {
+	return e.MatchRecords(checkName, RecordsFromText(content))
+}
+
+// MatchRecords 对检查项输出的结构化记录执行匹配
+func (e *RuleEngine) MatchRecords(checkName string, records []Record) []Finding {
 	var findings []Finding
 	rules, ok := e.rulesByCheck[checkName]
 	if !ok {
 		return findings
 	}
 
-	lines := strings.Split(content, "\n")
-
-	for _, rule := range rules {
+	// 关键词规则由自动机对每条记录扫描一次，得到各规则命中的记录
+	keywordHits := e.keywordHits(checkName, records)
+	for i, rule := range rules {
 		switch rule.Type {
 		case "keyword":
-			for _, line := range lines {
-				for _, pattern := range rule.Patterns {
-					if strings.Contains(line, pattern) {
-						findings = append(findings, Finding{
-							Source:      "Rule",
-							Name:        rule.Name,
-							Description: rule.Description,
-							RiskLevel:   rule.RiskLevel,
-							MatchedLine: line,
-						})
-						break
-					}
+			for _, r := range keywordHits[i] {
+				if rule.accepts(records[r]) {
+					findings = append(findings, rule.finding(records[r]))
 				}
 			}
 		case "regex":
-			for _, line := range lines {
+			for _, rec := range records {
+				value, ok := rule.target(rec)
+				if !ok {
+					continue
+				}
 				for _, re := range rule.precompiledPatterns {
-					if re.MatchString(line) {
-						findings = append(findings, Finding{
-							Source:      "Rule",
-							Name:        rule.Name,
-							Description: rule.Description,
-							RiskLevel:   rule.RiskLevel,
-							MatchedLine: line,
-						})
+					if re.MatchString(value) && rule.accepts(rec) {
+						findings = append(findings, rule.finding(rec))
 						break
 					}
 				}
 			}
 		case "agg_regex":
-			counts := make(map[string]int)
-			if len(rule.precompiledPatterns) == 0 {
-				continue
-			}
-			re := rule.precompiledPatterns[0]
-			for _, line := range lines {
-				matches := re.FindStringSubmatch(line)
-				if len(matches) > 1 {
-					counts[matches[1]]++
-				}
-			}
-
-			parts := strings.Fields(rule.Condition)
-			if len(parts) == 3 && parts[0] == "count" {
-				threshold, err := strconv.Atoi(parts[2])
-				if err != nil {
-					continue
-				}
-
-				for entity, count := range counts {
-					trigger := false
-					switch parts[1] {
-					case ">":
-						if count > threshold {
-							trigger = true
-						}
-					case "<":
-						if count < threshold {
-							trigger = true
-						}
-					case "==":
-						if count == threshold {
-							trigger = true
-						}
-					}
-					if trigger {
-						findings = append(findings, Finding{
-							Source:      "Rule",
-							Name:        rule.Name,
-							Description: rule.Description,
-							RiskLevel:   rule.RiskLevel,
-							MatchedLine: fmt.Sprintf("实体 '%s' 出现 %d 次, 触发条件 '%s'", entity, count, rule.Condition),
-						})
-					}
+			findings = append(findings, rule.aggregate(records)...)
+		case "condition":
+			for _, rec := range records {
+				if rule.accepts(rec) {
+					findings = append(findings, rule.finding(rec))
 				}
 			}
 		}
 	}
 	return findings
 }
+
+// finding 根据触发匹配的记录生成风险发现
+func (r Rule) finding(rec Record) Finding {
+	return Finding{
+		Source:      "Rule",
+		Name:        r.Name,
+		Description: r.Description,
+		RiskLevel:   r.RiskLevel,
+		MatchedLine: rec.Line(),
+		Field:       r.Field,
+		Record:      rec,
+		Attack:      attackPtr(r.Attack),
+	}
+}
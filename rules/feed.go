@@ -0,0 +1,260 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// addIOC 预编译 IOC 并按类型分组，未启用、已过期或无法编译的 IOC 会被跳过
+func (e *RuleEngine) addIOC(ioc IOC, now time.Time) {
+	if !ioc.Enabled {
+		return
+	}
+	if expired, err := ioc.Expired(now); err != nil {
+		fmt.Printf("警告: IOC '%s' 的失效时间无效, 已忽略: %v\n", ioc.Name, err)
+	} else if expired {
+		return
+	}
+	if ioc.MatchType == "regex" {
+		for _, indicator := range ioc.Indicators {
+			re, err := regexp.Compile(indicator)
+			if err != nil {
+				fmt.Printf("警告: 编译IOC '%s' 的正则表达式 '%s' 失败, 已跳过: %v\n", ioc.Name, indicator, err)
+				continue
+			}
+			ioc.precompiledIndicators = append(ioc.precompiledIndicators, re)
+		}
+	}
+	if attack, err := ioc.Attack.Normalize(); err != nil {
+		fmt.Printf("警告: IOC '%s' 的 ATT&CK 映射无效, 已忽略: %v\n", ioc.Name, err)
+		ioc.Attack = Attack{}
+	} else {
+		ioc.Attack = attack
+	}
+	if IsHashType(ioc.Type) && ioc.MatchType != "regex" {
+		for _, indicator := range ioc.Indicators {
+			value, err := ParseHashIndicator(ioc.Type, indicator)
+			if err != nil {
+				fmt.Printf("警告: IOC '%s' 的指标无效, 已跳过: %v\n", ioc.Name, err)
+				continue
+			}
+			e.hashes[value] = append(e.hashes[value], &ioc)
+		}
+		return
+	}
+	if ioc.Type == "ip" && ioc.MatchType != "regex" {
+		// IP 指标按地址或 CIDR 网段匹配，而不是按字符串匹配，以免 0.1.2.3 命中 10.1.2.3
+		for _, indicator := range ioc.Indicators {
+			prefix, err := ParseIPIndicator(indicator)
+			if err != nil {
+				fmt.Printf("警告: IOC '%s' 的指标无效, 已跳过: %v\n", ioc.Name, err)
+				continue
+			}
+			e.ips.insert(prefix, &ioc, indicator)
+		}
+		return
+	}
+	e.iocsByType[ioc.Type] = append(e.iocsByType[ioc.Type], ioc)
+}
+
+// matchIP 在 IP 类型的 IOC 中查找包含给定地址的指标，content 不是 IP 地址时没有匹配。
+// 同一个 IOC 中有多个指标包含该地址时只报告最精确的一个
+func (e *RuleEngine) matchIP(content string) []Finding {
+	addr, err := netip.ParseAddr(strings.TrimSpace(content))
+	if err != nil {
+		return nil
+	}
+	var findings []Finding
+	best := make(map[*IOC]int) // IOC -> 其最精确匹配在 findings 中的位置
+	bits := make(map[*IOC]int)
+	for _, entry := range e.ips.lookup(addr) {
+		i, seen := best[entry.ioc]
+		if seen && entry.prefix.Bits() <= bits[entry.ioc] {
+			continue
+		}
+		matched := fmt.Sprintf("匹配到指标 '%s'", entry.indicator)
+		if entry.prefix.Bits() < entry.prefix.Addr().BitLen() {
+			matched = fmt.Sprintf("匹配到网段指标 '%s' -> %s", entry.indicator, addr.Unmap().WithZone(""))
+		}
+		finding := Finding{
+			Source:      "IOC",
+			Name:        entry.ioc.Name,
+			Description: entry.ioc.describe(),
+			RiskLevel:   "High",
+			MatchedLine: matched,
+			Attack:      attackPtr(entry.ioc.Attack),
+		}
+		if seen {
+			findings[i] = finding
+		} else {
+			best[entry.ioc] = len(findings)
+			findings = append(findings, finding)
+		}
+		bits[entry.ioc] = entry.prefix.Bits()
+	}
+	return findings
+}
+
+// Expired 判断 IOC 在给定时间是否已过期，未设置失效时间的 IOC 永不过期。
+// 只有日期的失效时间在当天结束后过期
+func (ioc IOC) Expired(now time.Time) (bool, error) {
+	if ioc.ValidUntil == "" {
+		return false, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, ioc.ValidUntil); err == nil {
+		return !now.Before(t), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", ioc.ValidUntil, time.Local)
+	if err != nil {
+		return false, fmt.Errorf("'%s' 应为 RFC 3339 时间或 YYYY-MM-DD", ioc.ValidUntil)
+	}
+	return !now.Before(day.AddDate(0, 0, 1)), nil
+}
+
+// describe 返回风险发现中的说明，附带情报来源、置信度和有效期
+func (ioc IOC) describe() string {
+	var meta []string
+	if ioc.Source != "" {
+		meta = append(meta, "情报来源: "+ioc.Source)
+	}
+	if ioc.Confidence > 0 {
+		meta = append(meta, fmt.Sprintf("置信度: %d", ioc.Confidence))
+	}
+	if ioc.ValidUntil != "" {
+		meta = append(meta, "有效期至: "+ioc.ValidUntil)
+	}
+	if len(meta) == 0 {
+		return ioc.Description
+	}
+	return fmt.Sprintf("%s (%s)", ioc.Description, strings.Join(meta, ", "))
+}
+
+// FeedSkip 记录情报源中一个未导入的指标及原因
+type FeedSkip struct {
+	Indicator string // STIX 指标的名称或 ID、MISP 属性的类型和值
+	Reason    string
+}
+
+// ReadIOCFeed 读取一个 STIX 2.1 bundle 或 MISP 事件导出的 JSON 文件，根据内容自动识别格式，
+// 返回转换得到的 IOC 以及无法转换的指标
+func ReadIOCFeed(filePath string) ([]IOC, []FeedSkip, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var probe struct {
+		Type  string          `json:"type"`
+		Event json.RawMessage `json:"Event"`
+	}
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(content, &probe); err != nil {
+			return nil, nil, fmt.Errorf("无法解析情报文件 '%s': %w", filePath, err)
+		}
+	}
+	if probe.Type == "bundle" {
+		return parseSTIX(content)
+	}
+	iocs, skipped, err := parseMISP(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无法解析情报文件 '%s': 既不是 STIX 2.1 bundle 也不是 MISP 事件: %w", filePath, err)
+	}
+	return iocs, skipped, nil
+}
+
+// feedFiles 展开情报源路径，目录中的 .json 文件按文件名顺序读取。
+// 无法访问的路径不影响其他路径，返回可读取的文件和每个无法访问路径的错误
+func feedFiles(paths []string) (files []string, invalid []error) {
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			invalid = append(invalid, err)
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(p, "*.json"))
+		files = append(files, matches...)
+	}
+	return files, invalid
+}
+
+// LoadIOCFeeds 导入 STIX 2.1 和 MISP 格式的情报文件或目录，无法读取的文件会被跳过
+func (e *RuleEngine) LoadIOCFeeds(paths []string, now time.Time) {
+	files, invalid := feedFiles(paths)
+	for _, err := range invalid {
+		fmt.Printf("警告: 无法读取情报源, 已跳过: %v\n", err)
+	}
+	for _, file := range files {
+		iocs, skipped, err := ReadIOCFeed(file)
+		if err != nil {
+			fmt.Printf("警告: %v, 已跳过\n", err)
+			continue
+		}
+		indicators, expired := 0, 0
+		for _, ioc := range iocs {
+			if ok, _ := ioc.Expired(now); ok {
+				expired += len(ioc.Indicators)
+				continue
+			}
+			indicators += len(ioc.Indicators)
+			e.addIOC(ioc, now)
+		}
+		fmt.Printf("已从情报源 '%s' 导入 %d 个指标 (已过期 %d 个, 不支持 %d 个)\n", file, indicators, expired, len(skipped))
+	}
+	e.compileKeywords()
+}
+
+// feedIndicator 校验情报源中的指标值，哈希统一为小写
+func feedIndicator(iocType, value string) (string, error) {
+	if IsHashType(iocType) {
+		return ParseHashIndicator(iocType, value)
+	}
+	if iocType == "ip" {
+		if _, err := ParseIPIndicator(value); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+// feedIOC 创建一个由情报源导入的 IOC。IP、文件名和哈希使用精确匹配 (文件名与路径的最后一段比较)，域名和 URL 按关键词匹配以便在命令行中查找
+func feedIOC(iocType, name, description string, indicator string) IOC {
+	matchType := "exact"
+	if iocType == "domain" || iocType == "url" {
+		matchType = "keyword"
+	}
+	return IOC{
+		Name:        name,
+		Enabled:     true,
+		Type:        iocType,
+		Description: description,
+		MatchType:   matchType,
+		Indicators:  []string{indicator},
+	}
+}
+
+// appendIndicator 将指标并入同名同类型的 IOC，不存在时新建
+func appendIndicator(iocs []IOC, template IOC) []IOC {
+	for i := range iocs {
+		if iocs[i].Name == template.Name && iocs[i].Type == template.Type {
+			for _, existing := range iocs[i].Indicators {
+				if existing == template.Indicators[0] {
+					return iocs
+				}
+			}
+			iocs[i].Indicators = append(iocs[i].Indicators, template.Indicators...)
+			return iocs
+		}
+	}
+	return append(iocs, template)
+}
@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hashLengths 是各哈希类型 IOC 指标的十六进制长度
+var hashLengths = map[string]int{"md5": 32, "sha1": 40, "sha256": 64}
+
+// IsHashType 判断 IOC 类型是否为文件哈希 (md5、sha1、sha256)
+func IsHashType(iocType string) bool {
+	_, ok := hashLengths[iocType]
+	return ok
+}
+
+// ParseHashIndicator 校验哈希类型的指标并统一为小写十六进制
+func ParseHashIndicator(iocType, s string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	if len(value) != hashLengths[iocType] || strings.Trim(value, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("'%s' 不是有效的 %s 哈希 (应为 %d 位十六进制)", s, strings.ToUpper(iocType), hashLengths[iocType])
+	}
+	return value, nil
+}
+
+// FileHashes 记录风险发现所涉及文件的哈希值，便于在其他系统中关联检索
+type FileHashes struct {
+	Path   string
+	MD5    string
+	SHA1   string
+	SHA256 string
+}
+
+// String 返回如 "/tmp/x md5=... sha1=... sha256=..." 形式的摘要
+func (h FileHashes) String() string {
+	return fmt.Sprintf("%s md5=%s sha1=%s sha256=%s", h.Path, h.MD5, h.SHA1, h.SHA256)
+}
+
+// HashIOCsLoaded 判断是否加载了文件哈希类型的 IOC，没有时检查项可以跳过不必要的哈希计算
+func (e *RuleEngine) HashIOCsLoaded() bool {
+	return len(e.hashes) > 0
+}
+
+// MatchHashes 使用文件哈希类型的 IOC 匹配文件的哈希值，匹配结果中附带文件的全部哈希
+func (e *RuleEngine) MatchHashes(h FileHashes) []Finding {
+	var findings []Finding
+	for _, sum := range [][2]string{{"md5", h.MD5}, {"sha1", h.SHA1}, {"sha256", h.SHA256}} {
+		for _, ioc := range e.hashes[sum[1]] {
+			if ioc.Type != sum[0] {
+				continue
+			}
+			file := h
+			findings = append(findings, Finding{
+				Source:      "IOC",
+				Name:        ioc.Name,
+				Description: ioc.describe(),
+				RiskLevel:   "High",
+				MatchedLine: fmt.Sprintf("文件 '%s' 的 %s 匹配到指标 '%s'", h.Path, strings.ToUpper(sum[0]), sum[1]),
+				Attack:      attackPtr(ioc.Attack),
+				File:        &file,
+			})
+		}
+	}
+	return findings
+}
+
+// AttachFile 将文件哈希附加到尚未关联文件的风险发现上
+func AttachFile(findings []Finding, h *FileHashes) {
+	for i := range findings {
+		if findings[i].File == nil {
+			findings[i].File = h
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// ParseIPIndicator 将 IP 类型的指标解析为网段。单个地址 (IPv4 或 IPv6) 视为 /32 或 /128 的网段，
+// IPv4 映射的 IPv6 地址和网段按 IPv4 处理，IPv6 的区域标识 (如 %eth0) 会被忽略
+func ParseIPIndicator(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("'%s' 不是有效的 IP 地址或 CIDR 网段", s)
+		}
+		if p.Addr().Is4In6() {
+			if p.Bits() < 96 {
+				return netip.Prefix{}, fmt.Errorf("'%s' 不是有效的 IPv4 映射网段", s)
+			}
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("'%s' 不是有效的 IP 地址或 CIDR 网段", s)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ipEntry 是 IP 前缀树中的一个指标
+type ipEntry struct {
+	seq       int // 加载顺序，用于按 IOC 和指标的定义顺序输出匹配结果
+	ioc       *IOC
+	indicator string // 原始指标
+	prefix    netip.Prefix
+}
+
+type ipNode struct {
+	children [2]*ipNode
+	entries  []ipEntry
+}
+
+// ipSet 是按地址族划分的二叉前缀树，查找一个地址只需沿其比特位走一遍 (IPv4 最多 32 层，IPv6 最多 128 层)，
+// 与指标数量无关
+type ipSet struct {
+	v4, v6 ipNode
+	size   int
+}
+
+func (s *ipSet) root(addr netip.Addr) *ipNode {
+	if addr.Is4() {
+		return &s.v4
+	}
+	return &s.v6
+}
+
+// insert 将网段加入前缀树
+func (s *ipSet) insert(p netip.Prefix, ioc *IOC, indicator string) {
+	node := s.root(p.Addr())
+	raw := p.Addr().AsSlice()
+	for i := 0; i < p.Bits(); i++ {
+		bit := raw[i/8] >> (7 - i%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &ipNode{}
+		}
+		node = node.children[bit]
+	}
+	node.entries = append(node.entries, ipEntry{seq: s.size, ioc: ioc, indicator: indicator, prefix: p})
+	s.size++
+}
+
+// lookup 返回包含给定地址的所有指标，按加载顺序排列
+func (s *ipSet) lookup(addr netip.Addr) []ipEntry {
+	addr = addr.Unmap().WithZone("")
+	node := s.root(addr)
+	raw := addr.AsSlice()
+	var matched []ipEntry
+	for i := 0; node != nil; i++ {
+		matched = append(matched, node.entries...)
+		if i == addr.BitLen() {
+			break
+		}
+		node = node.children[raw[i/8]>>(7-i%8)&1]
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].seq < matched[j].seq })
+	return matched
+}
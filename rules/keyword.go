@@ -0,0 +1,79 @@
+package rules
+
+// keywordIndex 将同一检查项中匹配同一字段的全部关键词规则编译为一个自动机，
+// 每条记录的该字段只需扫描一次即可得到命中的全部规则
+type keywordIndex struct {
+	field string
+	ac    *matcher
+	rules []int // 模式编号 -> 规则在 rulesByCheck 中的位置
+}
+
+// iocIndex 将同一类型的全部关键词 IOC 编译为一个自动机
+type iocIndex struct {
+	ac     *matcher
+	hits   [][2]int // 模式编号 -> (IOC 在 iocsByType 中的位置, 指标位置)，按 IOC 和指标的定义顺序编号
+	others []int    // 正则和精确匹配的 IOC 在 iocsByType 中的位置
+}
+
+// compileKeywords 为关键词规则和关键词 IOC 构建自动机，在规则和 IOC 加载完成后调用
+func (e *RuleEngine) compileKeywords() {
+	e.keywordIndexes = make(map[string][]*keywordIndex)
+	for check, rules := range e.rulesByCheck {
+		byField := make(map[string]*keywordIndex)
+		for i, rule := range rules {
+			if rule.Type != "keyword" {
+				continue
+			}
+			index, ok := byField[rule.Field]
+			if !ok {
+				index = &keywordIndex{field: rule.Field, ac: newMatcher()}
+				byField[rule.Field] = index
+				e.keywordIndexes[check] = append(e.keywordIndexes[check], index)
+			}
+			for _, pattern := range rule.Patterns {
+				index.ac.add(pattern, len(index.rules))
+				index.rules = append(index.rules, i)
+			}
+		}
+		for _, index := range byField {
+			index.ac.build()
+		}
+	}
+
+	e.iocIndexes = make(map[string]*iocIndex)
+	for iocType, iocs := range e.iocsByType {
+		index := &iocIndex{ac: newMatcher()}
+		for i, ioc := range iocs {
+			if ioc.MatchType == "regex" || ioc.MatchType == "exact" {
+				index.others = append(index.others, i)
+				continue
+			}
+			for j, indicator := range ioc.Indicators {
+				index.ac.add(indicator, len(index.hits))
+				index.hits = append(index.hits, [2]int{i, j})
+			}
+		}
+		index.ac.build()
+		e.iocIndexes[iocType] = index
+	}
+}
+
+// keywordHits 扫描记录，返回每条关键词规则 (以其在 rulesByCheck 中的位置为键) 命中的记录位置，按记录顺序排列
+func (e *RuleEngine) keywordHits(checkName string, records []Record) map[int][]int {
+	hits := make(map[int][]int)
+	for _, index := range e.keywordIndexes[checkName] {
+		for i, rec := range records {
+			value, ok := Rule{Field: index.field}.target(rec)
+			if !ok {
+				continue
+			}
+			for _, id := range index.ac.scan(value) {
+				r := index.rules[id]
+				if n := len(hits[r]); n == 0 || hits[r][n-1] != i {
+					hits[r] = append(hits[r], i)
+				}
+			}
+		}
+	}
+	return hits
+}
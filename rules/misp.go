@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mispEvent 是 MISP 事件 JSON 导出中与情报导入相关的部分
+type mispEvent struct {
+	ID        string                `json:"id"`
+	UUID      string                `json:"uuid"`
+	Info      string                `json:"info"`
+	Orgc      struct{ Name string } `json:"Orgc"`
+	Attribute []mispAttribute       `json:"Attribute"`
+	Object    []struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+	Tag []mispTag `json:"Tag"`
+}
+
+type mispAttribute struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	ToIDS   bool   `json:"to_ids"`
+	Deleted bool   `json:"deleted"`
+}
+
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+// mispTypes 将 MISP 属性类型映射为 IOC 类型。复合类型 (如 filename|sha256) 的各部分分别映射，空字符串表示忽略该部分
+var mispTypes = map[string][]string{
+	"ip-src": {"ip"}, "ip-dst": {"ip"}, "ip-src|port": {"ip", ""}, "ip-dst|port": {"ip", ""},
+	"domain": {"domain"}, "hostname": {"domain"}, "domain|ip": {"domain", "ip"},
+	"url":      {"url"},
+	"filename": {"filename"},
+	"md5":      {"md5"}, "sha1": {"sha1"}, "sha256": {"sha256"},
+	"filename|md5": {"filename", "md5"}, "filename|sha1": {"filename", "sha1"}, "filename|sha256": {"filename", "sha256"},
+}
+
+// mispConfidence 将 estimative-language 分类法的置信度标签映射为 0-100 的置信度
+var mispConfidence = map[string]int{"low": 30, "moderate": 60, "high": 90}
+
+// mispTechniqueRe 从 MITRE ATT&CK 星系标签中提取技术编号，如
+// misp-galaxy:mitre-attack-pattern="Unix Shell - T1059.004"
+var mispTechniqueRe = regexp.MustCompile(`^misp-galaxy:mitre-attack-pattern=".*\b(T[0-9]{4}(?:\.[0-9]{3})?)"$`)
+
+// parseMISP 将 MISP 事件导出 ({"Event": {...}}、{"response": [...]}、事件数组) 转换为 IOC。
+// 每个事件的同类指标合并为一个 IOC；to_ids 为 false 的属性只用于上下文，不会导入
+func parseMISP(content []byte) ([]IOC, []FeedSkip, error) {
+	type wrapped struct {
+		Event *mispEvent `json:"Event"`
+	}
+	var events []mispEvent
+	var single wrapped
+	var response struct {
+		Response []wrapped `json:"response"`
+	}
+	var list []wrapped
+	switch {
+	case json.Unmarshal(content, &single) == nil && single.Event != nil:
+		events = append(events, *single.Event)
+	case json.Unmarshal(content, &response) == nil && len(response.Response) > 0:
+		for _, w := range response.Response {
+			if w.Event != nil {
+				events = append(events, *w.Event)
+			}
+		}
+	case json.Unmarshal(content, &list) == nil && len(list) > 0:
+		for _, w := range list {
+			if w.Event != nil {
+				events = append(events, *w.Event)
+			}
+		}
+	}
+	if len(events) == 0 {
+		return nil, nil, fmt.Errorf("未找到 MISP 事件")
+	}
+
+	var iocs []IOC
+	var skipped []FeedSkip
+	for _, event := range events {
+		id := event.ID
+		if id == "" {
+			id = event.UUID
+		}
+		name := "MISP_Event_" + id
+		description := "MISP 事件: " + event.Info
+		source := event.Orgc.Name
+		if source == "" {
+			source = "MISP"
+		}
+		confidence, attack := mispEventMeta(event.Tag)
+
+		attributes := event.Attribute
+		for _, obj := range event.Object {
+			attributes = append(attributes, obj.Attribute...)
+		}
+		for _, attr := range attributes {
+			if attr.Deleted || !attr.ToIDS {
+				continue
+			}
+			kinds, ok := mispTypes[attr.Type]
+			if !ok {
+				skipped = append(skipped, FeedSkip{Indicator: attr.Type + " " + attr.Value, Reason: fmt.Sprintf("不支持的属性类型 '%s'", attr.Type)})
+				continue
+			}
+			parts := strings.SplitN(attr.Value, "|", len(kinds))
+			if len(parts) != len(kinds) {
+				skipped = append(skipped, FeedSkip{Indicator: attr.Type + " " + attr.Value, Reason: "属性值与复合类型不符"})
+				continue
+			}
+			for i, kind := range kinds {
+				if kind == "" || parts[i] == "" {
+					continue
+				}
+				value, err := feedIndicator(kind, parts[i])
+				if err != nil {
+					skipped = append(skipped, FeedSkip{Indicator: attr.Type + " " + attr.Value, Reason: err.Error()})
+					continue
+				}
+				ioc := feedIOC(kind, name, description, value)
+				ioc.Source, ioc.Confidence, ioc.Attack = source, confidence, attack
+				iocs = appendIndicator(iocs, ioc)
+			}
+		}
+	}
+	return iocs, skipped, nil
+}
+
+// mispEventMeta 从事件标签中提取置信度 (estimative-language:confidence-in-analytic-judgment) 和 ATT&CK 技术
+func mispEventMeta(tags []mispTag) (int, Attack) {
+	confidence := 0
+	var attack Attack
+	for _, tag := range tags {
+		if strings.HasPrefix(tag.Name, "estimative-language:confidence-in-analytic-judgment=") {
+			level := strings.Trim(strings.SplitN(tag.Name, "=", 2)[1], `"`)
+			confidence = mispConfidence[level]
+		}
+		if m := mispTechniqueRe.FindStringSubmatch(tag.Name); m != nil {
+			attack.Techniques = append(attack.Techniques, m[1])
+		}
+	}
+	return confidence, attack
+}
@@ -0,0 +1,50 @@
+package rules
+
+import (
+	"sort"
+	"strings"
+)
+
+// Record 是检查项输出的一条结构化记录，由字段名映射到字段值。
+// 字段 line 保存记录的文本形式：未指定 field 的规则对其进行匹配，报告中也以它作为匹配内容展示
+type Record map[string]string
+
+// NewRecord 使用文本形式和字段创建记录，kv 为交替出现的字段名和字段值
+func NewRecord(line string, kv ...string) Record {
+	r := Record{"line": line}
+	for i := 0; i+1 < len(kv); i += 2 {
+		r[kv[i]] = kv[i+1]
+	}
+	return r
+}
+
+// Line 返回记录的文本形式
+func (r Record) Line() string { return r["line"] }
+
+// String 以 "key=value" 的形式按字段名排序输出除 line 以外的全部字段
+func (r Record) String() string {
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		if k != "line" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + r[k]
+	}
+	return strings.Join(parts, " ")
+}
+
+// RecordsFromText 将尚未结构化的文本按行转换为记录，空行会被忽略，行内的 key=value 片段被解析为字段
+func RecordsFromText(content string) []Record {
+	var records []Record
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		records = append(records, Record(ParseFields(line)))
+	}
+	return records
+}
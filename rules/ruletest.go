@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sample 是内嵌测试用例中的一条样例输入。可以写作字符串 (与检查项输出的文本一样，行内的 key=value 片段被解析为字段)，
+// 也可以写作字段映射，如 {exe: /tmp/x, cmdline: "/tmp/x -c"}，未提供 line 时由各字段拼接而成
+type Sample Record
+
+// UnmarshalYAML 支持字符串和字段映射两种写法
+func (s *Sample) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = Sample(ParseFields(node.Value))
+		return nil
+	}
+	var fields map[string]string
+	if err := node.Decode(&fields); err != nil {
+		return fmt.Errorf("测试样例应为字符串或字段映射: %w", err)
+	}
+	rec := Record(fields)
+	if _, ok := rec["line"]; !ok {
+		rec["line"] = rec.String()
+	}
+	*s = Sample(rec)
+	return nil
+}
+
+// Tests 是规则或 IOC 中内嵌的测试用例，由 -validate-rules 使用真实的规则引擎执行
+type Tests struct {
+	ShouldMatch    []Sample `yaml:"should_match,omitempty"`
+	ShouldNotMatch []Sample `yaml:"should_not_match,omitempty"`
+}
+
+// Count 返回测试用例的数量
+func (t Tests) Count() int {
+	return len(t.ShouldMatch) + len(t.ShouldNotMatch)
+}
+
+// TestFailure 描述一个未通过的测试用例
+type TestFailure struct {
+	Case   string // 如 should_match #2
+	Sample string
+	Reason string
+}
+
+func (f TestFailure) String() string {
+	return fmt.Sprintf("%s '%s': %s", f.Case, f.Sample, f.Reason)
+}
+
+// RunTests 在只包含这条规则的规则引擎中执行其测试用例。每条样例单独作为一条记录匹配；
+// agg_regex 规则需要多条记录才能聚合，因此 should_match 和 should_not_match 各自作为一组记录整体匹配
+func (r Rule) RunTests() ([]TestFailure, error) {
+	engine := newEngine()
+	r.Enabled = true
+	engine.addRule(r)
+	if len(engine.rulesByCheck[r.TargetCheck]) == 0 {
+		return nil, fmt.Errorf("规则无法加载")
+	}
+	engine.compileKeywords()
+
+	var failures []TestFailure
+	run := func(name string, samples []Sample, want bool) {
+		if r.Type == "agg_regex" {
+			if len(samples) == 0 {
+				return
+			}
+			records := make([]Record, len(samples))
+			for i, s := range samples {
+				records[i] = Record(s)
+			}
+			if f := checkMatch(name, fmt.Sprintf("%d 条记录", len(records)), engine.MatchRecords(r.TargetCheck, records), want); f != nil {
+				failures = append(failures, *f)
+			}
+			return
+		}
+		for i, s := range samples {
+			rec := Record(s)
+			if f := checkMatch(fmt.Sprintf("%s #%d", name, i+1), rec.Line(), engine.MatchRecords(r.TargetCheck, []Record{rec}), want); f != nil {
+				failures = append(failures, *f)
+			}
+		}
+	}
+	run("should_match", r.Tests.ShouldMatch, true)
+	run("should_not_match", r.Tests.ShouldNotMatch, false)
+	return failures, nil
+}
+
+// RunTests 在只包含这个 IOC 的规则引擎中执行其测试用例。样例为检查项交给 IOC 匹配的内容，
+// 如 IP 地址、文件路径、命令行或文件的哈希值
+func (ioc IOC) RunTests() ([]TestFailure, error) {
+	engine := newEngine()
+	ioc.Enabled, ioc.ValidUntil = true, ""
+	engine.addIOC(ioc, time.Now())
+	if len(engine.iocsByType) == 0 && engine.ips.size == 0 && len(engine.hashes) == 0 {
+		return nil, fmt.Errorf("IOC 没有可加载的指标")
+	}
+	engine.compileKeywords()
+
+	var failures []TestFailure
+	for _, c := range []struct {
+		name    string
+		samples []Sample
+		want    bool
+	}{{"should_match", ioc.Tests.ShouldMatch, true}, {"should_not_match", ioc.Tests.ShouldNotMatch, false}} {
+		for i, s := range c.samples {
+			content := Record(s).Line()
+			var findings []Finding
+			if IsHashType(ioc.Type) {
+				h, digest := FileHashes{Path: "(测试样例)"}, strings.ToLower(content)
+				switch ioc.Type {
+				case "md5":
+					h.MD5 = digest
+				case "sha1":
+					h.SHA1 = digest
+				case "sha256":
+					h.SHA256 = digest
+				}
+				findings = engine.MatchHashes(h)
+			} else {
+				findings = engine.MatchIOC(ioc.Type, content)
+			}
+			if f := checkMatch(fmt.Sprintf("%s #%d", c.name, i+1), content, findings, c.want); f != nil {
+				failures = append(failures, *f)
+			}
+		}
+	}
+	return failures, nil
+}
+
+// checkMatch 比较匹配结果与预期，不符合时返回失败原因
+func checkMatch(name, sample string, findings []Finding, want bool) *TestFailure {
+	switch {
+	case want && len(findings) == 0:
+		return &TestFailure{Case: name, Sample: sample, Reason: "应当匹配但没有产生风险发现"}
+	case !want && len(findings) > 0:
+		return &TestFailure{Case: name, Sample: sample, Reason: fmt.Sprintf("不应匹配但产生了风险发现: %s", findings[0].MatchedLine)}
+	}
+	return nil
+}
@@ -0,0 +1,698 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SigmaRule 是 Sigma 规则中与转换相关的部分，参见 https://github.com/SigmaHQ/sigma-specification
+type SigmaRule struct {
+	Title       string                 `yaml:"title"`
+	ID          string                 `yaml:"id"`
+	Status      string                 `yaml:"status"`
+	Description string                 `yaml:"description"`
+	Level       string                 `yaml:"level"`
+	Tags        []string               `yaml:"tags"`
+	Action      string                 `yaml:"action"`
+	LogSource   SigmaLogSource         `yaml:"logsource"`
+	Detection   map[string]interface{} `yaml:"detection"`
+}
+
+// SigmaLogSource 描述 Sigma 规则适用的日志来源
+type SigmaLogSource struct {
+	Product  string `yaml:"product"`
+	Category string `yaml:"category"`
+	Service  string `yaml:"service"`
+}
+
+// SigmaIssue 记录一条无法转换的 Sigma 规则及其原因
+type SigmaIssue struct {
+	File    string
+	Title   string
+	Reason  string
+	Invalid bool // 文件无法读取或不是有效的 YAML，而不是规则中含有无法转换的结构
+}
+
+func (i SigmaIssue) String() string {
+	title := i.Title
+	if title == "" {
+		title = "(无标题)"
+	}
+	if i.File == "" {
+		return fmt.Sprintf("%s: %s", title, i.Reason)
+	}
+	return fmt.Sprintf("%s [%s]: %s", i.File, title, i.Reason)
+}
+
+// sigmaTarget 描述一种 Sigma 日志来源对应的检查项，以及 Sigma 字段到记录字段的映射。
+// fields 为 nil 表示字段名原样使用 (如 auditd 日志本身就是 key=value 形式)
+type sigmaTarget struct {
+	check  string
+	fields map[string]string
+}
+
+// sigmaCategories 是支持转换的 Linux 日志类别
+var sigmaCategories = map[string]sigmaTarget{
+	"process_creation": {check: "SuspiciousProcessesCheck", fields: map[string]string{
+		"Image": "exe", "CommandLine": "cmdline", "User": "user", "CurrentDirectory": "cwd",
+		"ProcessId": "pid", "ParentProcessId": "ppid",
+		"ParentImage": "parent_exe", "ParentCommandLine": "parent_cmdline",
+	}},
+	"file_event": {check: "RecentlyModifiedFilesCheck", fields: map[string]string{
+		"TargetFilename": "path",
+	}},
+	"network_connection": {check: "EstablishedConnectionsCheck", fields: map[string]string{
+		"DestinationIp": "remote_ip", "DestinationPort": "remote_port",
+		"SourceIp": "local_ip", "SourcePort": "local_port",
+		"Image": "exe", "ProcessId": "pid",
+	}},
+}
+
+// sigmaServices 是支持转换的 Linux 日志服务
+var sigmaServices = map[string]sigmaTarget{
+	"auditd": {check: "AuditLogCheck"},
+}
+
+// sigmaLevels 将 Sigma 的级别映射为风险等级
+var sigmaLevels = map[string]string{
+	"informational": "Low", "low": "Low", "medium": "Medium", "high": "High", "critical": "Critical",
+}
+
+// LoadSigma 转换 Sigma 规则文件，path 为目录时递归读取其中的 .yml 和 .yaml 文件。
+// 返回成功转换的规则和无法转换的规则，单个文件读取或解析失败也作为无法转换的规则返回
+func LoadSigma(path string) ([]Rule, []SigmaIssue, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() && (filepath.Ext(p) == ".yml" || filepath.Ext(p) == ".yaml") {
+				files = append(files, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var converted []Rule
+	var issues []SigmaIssue
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err == nil {
+			var rules []Rule
+			var fileIssues []SigmaIssue
+			rules, fileIssues, err = ConvertSigma(content)
+			converted = append(converted, rules...)
+			for _, issue := range fileIssues {
+				issue.File = file
+				issues = append(issues, issue)
+			}
+		}
+		if err != nil {
+			issues = append(issues, SigmaIssue{File: file, Reason: err.Error(), Invalid: true})
+		}
+	}
+	return converted, issues, nil
+}
+
+// ConvertSigma 将 Sigma 规则 (一个文件中可包含多个 YAML 文档) 转换为 condition 类型的 goDetect 规则。
+// 包含无法转换的结构的规则会被整条跳过并通过 issues 返回，以免只转换一部分条件造成检测范围被扩大
+func ConvertSigma(content []byte) (converted []Rule, issues []SigmaIssue, err error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var sr SigmaRule
+		if err := decoder.Decode(&sr); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return converted, issues, fmt.Errorf("YAML 解析失败: %w", err)
+		}
+		if sr.Title == "" && sr.Detection == nil {
+			continue // 空文档
+		}
+		rule, err := convertSigmaRule(sr)
+		if err != nil {
+			issues = append(issues, SigmaIssue{Title: sr.Title, Reason: err.Error()})
+			continue
+		}
+		converted = append(converted, rule)
+	}
+	return converted, issues, nil
+}
+
+func convertSigmaRule(sr SigmaRule) (Rule, error) {
+	if sr.Action != "" {
+		return Rule{}, fmt.Errorf("不支持 Sigma 规则集合 (action: %s)", sr.Action)
+	}
+	if sr.Status == "deprecated" || sr.Status == "unsupported" {
+		return Rule{}, fmt.Errorf("规则状态为 %s", sr.Status)
+	}
+	target, err := sigmaTargetFor(sr.LogSource)
+	if err != nil {
+		return Rule{}, err
+	}
+	if sr.Detection == nil {
+		return Rule{}, fmt.Errorf("缺少 detection")
+	}
+
+	conv := sigmaConverter{target: target, selections: make(map[string]string)}
+	var conditions []string
+	keys := make([]string, 0, len(sr.Detection))
+	for key := range sr.Detection {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := sr.Detection[key]
+		switch key {
+		case "condition":
+			switch c := value.(type) {
+			case string:
+				conditions = []string{c}
+			case []interface{}:
+				for _, item := range c {
+					s, ok := item.(string)
+					if !ok {
+						return Rule{}, fmt.Errorf("condition 格式无效")
+					}
+					conditions = append(conditions, s)
+				}
+			default:
+				return Rule{}, fmt.Errorf("condition 格式无效")
+			}
+		case "timeframe":
+			return Rule{}, fmt.Errorf("不支持时间窗口 (timeframe)")
+		default:
+			expr, err := conv.selection(value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("选择 '%s': %w", key, err)
+			}
+			conv.selections[key] = expr
+		}
+	}
+	if len(conditions) == 0 {
+		return Rule{}, fmt.Errorf("缺少 condition")
+	}
+	var exprs []string
+	for _, c := range conditions {
+		expr, err := conv.condition(c)
+		if err != nil {
+			return Rule{}, fmt.Errorf("condition '%s': %w", c, err)
+		}
+		exprs = append(exprs, expr)
+	}
+	condition := strings.Join(exprs, " or ")
+	if len(exprs) > 1 {
+		condition = "(" + strings.Join(exprs, ") or (") + ")"
+	}
+	if _, err := CompileCondition(condition); err != nil {
+		return Rule{}, fmt.Errorf("生成的条件表达式无效: %w", err)
+	}
+
+	level, ok := sigmaLevels[strings.ToLower(sr.Level)]
+	if !ok {
+		level = "Medium"
+	}
+	description := strings.TrimSpace(sr.Description)
+	if description == "" {
+		description = sr.Title
+	}
+	description += fmt.Sprintf(" (Sigma: %s", sr.Title)
+	if sr.ID != "" {
+		description += ", ID: " + sr.ID
+	}
+	description += ")"
+	return Rule{
+		Name:        sigmaRuleName(sr.Title),
+		Enabled:     true,
+		Description: description,
+		TargetCheck: target.check,
+		Type:        "condition",
+		Condition:   condition,
+		RiskLevel:   level,
+		Attack:      sigmaAttack(sr.Tags),
+	}, nil
+}
+
+// sigmaAttack 从 Sigma 的标签中提取 ATT&CK 映射，如 attack.persistence 和 attack.t1053.003，
+// 组织 (attack.gXXXX) 和软件 (attack.sXXXX) 标签会被忽略
+func sigmaAttack(tags []string) Attack {
+	var a Attack
+	for _, tag := range tags {
+		name := strings.ToLower(tag)
+		if !strings.HasPrefix(name, "attack.") {
+			continue
+		}
+		name = strings.TrimPrefix(name, "attack.")
+		if t, ok := LookupTactic(name); ok {
+			a.Tactics = append(a.Tactics, t.Name)
+		} else if id := strings.ToUpper(name); techniqueRe.MatchString(id) {
+			a.Techniques = append(a.Techniques, id)
+		}
+	}
+	return a
+}
+
+// sigmaTargetFor 根据日志来源确定目标检查项，只支持 product 为 linux 的规则
+func sigmaTargetFor(ls SigmaLogSource) (sigmaTarget, error) {
+	if !strings.EqualFold(ls.Product, "linux") {
+		return sigmaTarget{}, fmt.Errorf("不支持的日志来源 product '%s'，只支持 linux", ls.Product)
+	}
+	if ls.Category != "" {
+		if t, ok := sigmaCategories[ls.Category]; ok {
+			return t, nil
+		}
+		return sigmaTarget{}, fmt.Errorf("不支持的日志类别 category '%s'", ls.Category)
+	}
+	if t, ok := sigmaServices[ls.Service]; ok {
+		return t, nil
+	}
+	return sigmaTarget{}, fmt.Errorf("不支持的日志服务 service '%s'", ls.Service)
+}
+
+// sigmaRuleName 由 Sigma 标题生成规则名，如 "Linux Reverse Shell" -> Sigma_Linux_Reverse_Shell
+func sigmaRuleName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(title))
+	for strings.Contains(name, "__") {
+		name = strings.ReplaceAll(name, "__", "_")
+	}
+	return "Sigma_" + strings.Trim(name, "_")
+}
+
+type sigmaConverter struct {
+	target     sigmaTarget
+	selections map[string]string // 选择名称到条件表达式
+}
+
+// selection 转换一个检测选择: 字段映射表示各字段条件同时成立，映射列表表示任一映射成立，
+// 字符串列表表示关键词，即整行中包含任一关键词
+func (c sigmaConverter) selection(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return c.fieldMap(v)
+	case []interface{}:
+		var exprs []string
+		for _, item := range v {
+			var expr string
+			var err error
+			if m, ok := item.(map[string]interface{}); ok {
+				expr, err = c.fieldMap(m)
+			} else {
+				expr, err = c.values("line", []string{"contains"}, item)
+			}
+			if err != nil {
+				return "", err
+			}
+			exprs = append(exprs, expr)
+		}
+		return joinExprs(exprs, "or"), nil
+	case nil:
+		return "", fmt.Errorf("选择为空")
+	default:
+		return c.values("line", []string{"contains"}, v)
+	}
+}
+
+func (c sigmaConverter) fieldMap(m map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var exprs []string
+	for _, key := range keys {
+		parts := strings.Split(key, "|")
+		field, modifiers := parts[0], parts[1:]
+		if field == "" {
+			field = "line" // 带修饰符的关键词，如 '|contains'
+		} else if c.target.fields != nil {
+			mapped, ok := c.target.fields[field]
+			if !ok {
+				return "", fmt.Errorf("字段 '%s' 无法映射到 %s 的记录字段", field, c.target.check)
+			}
+			field = mapped
+		} else if !isConditionIdent(field) {
+			return "", fmt.Errorf("字段名 '%s' 无法在条件表达式中使用", field)
+		}
+		expr, err := c.values(field, modifiers, m[key])
+		if err != nil {
+			return "", fmt.Errorf("字段 '%s': %w", parts[0], err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return joinExprs(exprs, "and"), nil
+}
+
+// values 转换一个字段的取值。取值为列表时任一值匹配即可，使用 all 修饰符时需要全部匹配
+func (c sigmaConverter) values(field string, modifiers []string, value interface{}) (string, error) {
+	all := false
+	var ops []string
+	for _, m := range modifiers {
+		switch m {
+		case "all":
+			all = true
+		case "contains", "startswith", "endswith", "re", "i", "m", "s", "exists", "gt", "gte", "lt", "lte":
+			ops = append(ops, m)
+		default:
+			return "", fmt.Errorf("不支持的修饰符 '%s'", m)
+		}
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	var exprs []string
+	for _, item := range list {
+		expr, err := sigmaValue(field, ops, item)
+		if err != nil {
+			return "", err
+		}
+		exprs = append(exprs, expr)
+	}
+	if all {
+		return joinExprs(exprs, "and"), nil
+	}
+	return joinExprs(exprs, "or"), nil
+}
+
+// sigmaValue 将单个取值转换为条件表达式。Sigma 的字符串匹配不区分大小写，正则表达式默认区分大小写
+func sigmaValue(field string, ops []string, value interface{}) (string, error) {
+	has := func(op string) bool {
+		for _, o := range ops {
+			if o == op {
+				return true
+			}
+		}
+		return false
+	}
+	if value == nil {
+		return field + " == ''", nil
+	}
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case int, int64, float64, bool:
+		s = fmt.Sprint(v)
+	default:
+		return "", fmt.Errorf("不支持的取值类型 %T", value)
+	}
+
+	switch {
+	case has("exists"):
+		if s == "true" {
+			return field + " != ''", nil
+		}
+		return field + " == ''", nil
+	case has("gt"), has("gte"), has("lt"), has("lte"):
+		op := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+		for _, o := range ops {
+			if symbol, ok := op[o]; ok {
+				return fmt.Sprintf("%s %s %s", field, symbol, quoteCondition(s)), nil
+			}
+		}
+	case has("re"):
+		flags := ""
+		for _, f := range []string{"i", "m", "s"} {
+			if has(f) {
+				flags += f
+			}
+		}
+		if flags != "" {
+			s = "(?" + flags + ")" + s
+		}
+		if _, err := regexp.Compile(s); err != nil {
+			return "", fmt.Errorf("正则表达式无效: %w", err)
+		}
+		return field + " matches " + quoteCondition(s), nil
+	}
+
+	glob := parseSigmaGlob(s)
+	if has("contains") || has("endswith") {
+		glob = append([]globItem{{wildcard: '*'}}, glob...)
+	}
+	if has("contains") || has("startswith") {
+		glob = append(glob, globItem{wildcard: '*'})
+	}
+	return glob.expr(field), nil
+}
+
+// globItem 是 Sigma 通配符值中的一个字符: 普通字符，或通配符 * 和 ?
+type globItem struct {
+	char     rune
+	wildcard rune
+}
+
+type sigmaGlob []globItem
+
+// parseSigmaGlob 解析 Sigma 值中的通配符，反斜杠可以转义 *、? 和反斜杠自身
+func parseSigmaGlob(s string) sigmaGlob {
+	var glob sigmaGlob
+	r := []rune(s)
+	for i := 0; i < len(r); i++ {
+		switch {
+		case r[i] == '\\' && i+1 < len(r) && (r[i+1] == '*' || r[i+1] == '?' || r[i+1] == '\\'):
+			i++
+			glob = append(glob, globItem{char: r[i]})
+		case r[i] == '*' || r[i] == '?':
+			glob = append(glob, globItem{wildcard: r[i]})
+		default:
+			glob = append(glob, globItem{char: r[i]})
+		}
+	}
+	return glob
+}
+
+// expr 生成匹配通配符值的条件表达式。只有首尾为 * 的值使用 contains/startswith/endswith，其余使用正则表达式
+func (g sigmaGlob) expr(field string) string {
+	start, end := 0, len(g)
+	for start < end && g[start].wildcard == '*' {
+		start++
+	}
+	for end > start && g[end-1].wildcard == '*' {
+		end--
+	}
+	leading, trailing := start > 0, end < len(g)
+	middle := g[start:end]
+
+	literal := true
+	var sb strings.Builder
+	for _, item := range middle {
+		if item.wildcard != 0 {
+			literal = false
+			break
+		}
+		sb.WriteRune(item.char)
+	}
+	if literal {
+		value := quoteCondition(strings.ToLower(sb.String()))
+		subject := "lower(" + field + ")"
+		switch {
+		case sb.Len() == 0 && (leading || trailing):
+			return field + " != ''"
+		case leading && trailing:
+			return subject + " contains " + value
+		case trailing:
+			return subject + " startswith " + value
+		case leading:
+			return subject + " endswith " + value
+		default:
+			return subject + " == " + value
+		}
+	}
+
+	var re strings.Builder
+	re.WriteString("(?is)^")
+	for _, item := range g {
+		switch item.wildcard {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(item.char)))
+		}
+	}
+	re.WriteString("$")
+	return field + " matches " + quoteCondition(re.String())
+}
+
+// condition 将 Sigma 的 condition 转换为条件表达式
+func (c sigmaConverter) condition(src string) (string, error) {
+	if strings.Contains(src, "|") {
+		return "", fmt.Errorf("不支持聚合表达式")
+	}
+	p := &sigmaCondParser{tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(src)), conv: c}
+	expr, err := p.parseOr()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.tokens) {
+		return "", fmt.Errorf("在 '%s' 处有多余的内容", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+type sigmaCondParser struct {
+	tokens []string
+	pos    int
+	conv   sigmaConverter
+}
+
+func (p *sigmaCondParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos])
+	}
+	return ""
+}
+
+func (p *sigmaCondParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = left + " or " + right
+	}
+	return left, nil
+}
+
+func (p *sigmaCondParser) parseAnd() (string, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return "", err
+	}
+	for p.peek() == "and" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		left = left + " and " + right
+	}
+	return left, nil
+}
+
+func (p *sigmaCondParser) parseNot() (string, error) {
+	if p.peek() == "not" {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return "", err
+		}
+		return "not " + operand, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *sigmaCondParser) parsePrimary() (string, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return "", fmt.Errorf("条件不完整")
+	case tok == "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if p.peek() != ")" {
+			return "", fmt.Errorf("缺少 ')'")
+		}
+		p.pos++
+		return "(" + expr + ")", nil
+	case (tok == "1" || tok == "any" || tok == "all") && p.pos+1 < len(p.tokens) && strings.EqualFold(p.tokens[p.pos+1], "of"):
+		if p.pos+2 >= len(p.tokens) {
+			return "", fmt.Errorf("'%s of' 之后缺少选择名称", tok)
+		}
+		pattern := p.tokens[p.pos+2]
+		p.pos += 3
+		var names []string
+		for name := range p.conv.selections {
+			if strings.EqualFold(pattern, "them") {
+				if !strings.HasPrefix(name, "_") {
+					names = append(names, name)
+				}
+			} else if ok, _ := path.Match(pattern, name); ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("'%s' 没有匹配任何选择", pattern)
+		}
+		sort.Strings(names)
+		var exprs []string
+		for _, name := range names {
+			exprs = append(exprs, "("+p.conv.selections[name]+")")
+		}
+		if tok == "all" {
+			return "(" + strings.Join(exprs, " and ") + ")", nil
+		}
+		return "(" + strings.Join(exprs, " or ") + ")", nil
+	default:
+		name := p.tokens[p.pos]
+		expr, ok := p.conv.selections[name]
+		if !ok {
+			return "", fmt.Errorf("引用了不存在的选择 '%s'", name)
+		}
+		p.pos++
+		return "(" + expr + ")", nil
+	}
+}
+
+// joinExprs 用 and 或 or 连接多个表达式，必要时加括号
+func joinExprs(exprs []string, op string) string {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return "(" + strings.Join(exprs, ") "+op+" (") + ")"
+}
+
+// quoteCondition 将字符串转换为条件表达式中的单引号字符串常量
+func quoteCondition(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// isConditionIdent 判断字段名能否直接作为条件表达式中的标识符
+func isConditionIdent(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '.'))) {
+			return false
+		}
+	}
+	return s != "" && !conditionKeywords[strings.ToLower(s)]
+}
+
+// conditionKeywords 是条件表达式中的关键字，不能用作字段名
+var conditionKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "contains": true, "startswith": true,
+	"endswith": true, "matches": true, "starts": true, "ends": true, "with": true,
+	"true": true, "false": true,
+}
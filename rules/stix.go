@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// stixObject 是 STIX 2.1 对象中与情报导入相关的部分
+type stixObject struct {
+	Type            string `json:"type"`
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	Pattern         string `json:"pattern"`
+	PatternType     string `json:"pattern_type"`
+	ValidUntil      string `json:"valid_until"`
+	Confidence      int    `json:"confidence"`
+	CreatedByRef    string `json:"created_by_ref"`
+	Revoked         bool   `json:"revoked"`
+	KillChainPhases []struct {
+		KillChainName string `json:"kill_chain_name"`
+		PhaseName     string `json:"phase_name"`
+	} `json:"kill_chain_phases"`
+}
+
+// stixComparisonRe 匹配 STIX 模式中的比较表达式，如 ipv4-addr:value = '1.2.3.4'、
+// file:hashes.'SHA-256' = '...' 以及 domain-name:value IN ('a.com', 'b.com')
+var stixComparisonRe = regexp.MustCompile(`([a-z0-9-]+):([A-Za-z0-9_.'-]+)\s*(=|IN)\s*('(?:[^'\\]|\\.)*'|\((?:\s*'(?:[^'\\]|\\.)*'\s*,?)*\))`)
+
+// stixStringRe 匹配 STIX 模式中的字符串常量
+var stixStringRe = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+
+// stixOrOnlyRe 匹配去掉比较表达式后只剩下 OR、方括号和圆括号的模式
+var stixOrOnlyRe = regexp.MustCompile(`^(?:\s|\[|\]|\(|\)|\bOR\b)*$`)
+
+// parseSTIX 将 STIX 2.1 bundle 中的 indicator 对象转换为 IOC。只支持由 = 或 IN 比较以 OR 组合而成的
+// STIX 模式，含有 AND、FOLLOWEDBY 等需要关联多个观测的模式无法用单个指标表示，会被跳过
+func parseSTIX(content []byte) ([]IOC, []FeedSkip, error) {
+	var bundle struct {
+		Objects []stixObject `json:"objects"`
+	}
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		return nil, nil, fmt.Errorf("无法解析 STIX bundle: %w", err)
+	}
+	identities := make(map[string]string)
+	for _, obj := range bundle.Objects {
+		if obj.Type == "identity" {
+			identities[obj.ID] = obj.Name
+		}
+	}
+
+	var iocs []IOC
+	var skipped []FeedSkip
+	for _, obj := range bundle.Objects {
+		if obj.Type != "indicator" {
+			continue
+		}
+		name := obj.Name
+		if name == "" {
+			name = obj.ID
+		}
+		if obj.Revoked {
+			skipped = append(skipped, FeedSkip{Indicator: name, Reason: "已撤销 (revoked)"})
+			continue
+		}
+		if obj.PatternType != "" && obj.PatternType != "stix" {
+			skipped = append(skipped, FeedSkip{Indicator: name, Reason: fmt.Sprintf("不支持的模式类型 '%s'", obj.PatternType)})
+			continue
+		}
+		values, err := stixPatternValues(obj.Pattern)
+		if err != nil {
+			skipped = append(skipped, FeedSkip{Indicator: name, Reason: err.Error()})
+			continue
+		}
+
+		source := identities[obj.CreatedByRef]
+		if source == "" {
+			source = "STIX"
+		}
+		description := obj.Description
+		if description == "" {
+			description = "STIX 情报指标: " + name
+		}
+		var attack Attack
+		for _, phase := range obj.KillChainPhases {
+			if phase.KillChainName == "mitre-attack" {
+				if t, ok := LookupTactic(phase.PhaseName); ok {
+					attack.Tactics = append(attack.Tactics, t.Name)
+				}
+			}
+		}
+		for _, v := range values {
+			ioc := feedIOC(v[0], name, description, v[1])
+			ioc.Source, ioc.Confidence, ioc.ValidUntil, ioc.Attack = source, obj.Confidence, obj.ValidUntil, attack
+			iocs = appendIndicator(iocs, ioc)
+		}
+	}
+	return iocs, skipped, nil
+}
+
+// stixPatternValues 从 STIX 模式中提取 (IOC 类型, 指标值) 列表
+func stixPatternValues(pattern string) ([][2]string, error) {
+	rest := stixComparisonRe.ReplaceAllString(pattern, "")
+	if !stixOrOnlyRe.MatchString(rest) {
+		return nil, fmt.Errorf("不支持的 STIX 模式 (只支持以 OR 组合的 = 和 IN 比较): %s", pattern)
+	}
+	var values [][2]string
+	for _, m := range stixComparisonRe.FindAllStringSubmatch(pattern, -1) {
+		iocType, ok := stixIOCType(m[1], m[2])
+		if !ok {
+			return nil, fmt.Errorf("不支持的观测对象属性 '%s:%s'", m[1], m[2])
+		}
+		for _, lit := range stixStringRe.FindAllStringSubmatch(m[4], -1) {
+			value, err := feedIndicator(iocType, strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(lit[1]))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, [2]string{iocType, value})
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("STIX 模式中没有可用的指标: %s", pattern)
+	}
+	return values, nil
+}
+
+// stixIOCType 将 STIX 观测对象的属性映射为 IOC 类型
+func stixIOCType(object, property string) (string, bool) {
+	switch object + ":" + property {
+	case "ipv4-addr:value", "ipv6-addr:value":
+		return "ip", true
+	case "domain-name:value":
+		return "domain", true
+	case "url:value":
+		return "url", true
+	case "file:name":
+		return "filename", true
+	}
+	if object == "file" && strings.HasPrefix(property, "hashes.") {
+		switch strings.ToUpper(strings.NewReplacer("'", "", "-", "").Replace(strings.TrimPrefix(property, "hashes."))) {
+		case "MD5":
+			return "md5", true
+		case "SHA1":
+			return "sha1", true
+		case "SHA256":
+			return "sha256", true
+		}
+	}
+	return "", false
+}
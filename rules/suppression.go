@@ -0,0 +1,209 @@
+package rules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suppression 是一条抑制规则，用于屏蔽已确认为正常的风险发现。
+// 所有已填写的匹配条件都满足时发现才会被抑制；reason、owner 和 expires 为必填项，过期后抑制规则自动失效
+type Suppression struct {
+	Rule       string `yaml:"rule"`        // 发现名称 (规则名、IOC名称等)，支持 * 和 ? 通配符
+	Check      string `yaml:"check"`       // 检查项名称
+	Field      string `yaml:"field"`       // match 和 match_regex 匹配的记录字段，为空时匹配发现的匹配内容
+	Match      string `yaml:"match"`       // 匹配值中需要包含的字符串
+	MatchRegex string `yaml:"match_regex"` // 匹配值需要满足的正则表达式
+	Host       string `yaml:"host"`        // 主机名，支持 * 和 ? 通配符
+	Reason     string `yaml:"reason"`      // 抑制理由
+	Owner      string `yaml:"owner"`       // 负责人
+	Expires    string `yaml:"expires"`     // 失效日期 (YYYY-MM-DD)，当天结束后失效
+
+	re        *regexp.Regexp
+	expiresAt time.Time
+}
+
+// SuppressionFile 定义了抑制规则文件的结构
+type SuppressionFile struct {
+	Suppressions []Suppression `yaml:"suppressions"`
+}
+
+// SuppressedFinding 是一条被抑制的风险发现及其对应的抑制规则
+type SuppressedFinding struct {
+	Finding
+	Reason  string
+	Owner   string
+	Expires string
+}
+
+// Compile 检查抑制规则的必填项并预编译正则表达式和失效日期
+func (s *Suppression) Compile() error {
+	var missing []string
+	for _, kv := range [][2]string{{"reason", s.Reason}, {"owner", s.Owner}, {"expires", s.Expires}} {
+		if strings.TrimSpace(kv[1]) == "" {
+			missing = append(missing, kv[0])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("缺少必填项: %s", strings.Join(missing, ", "))
+	}
+	if s.Rule == "" && s.Check == "" {
+		return fmt.Errorf("必须至少指定 rule 或 check，以免误抑制所有发现")
+	}
+	for _, pattern := range []string{s.Rule, s.Host} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("通配符 '%s' 无效: %w", pattern, err)
+		}
+	}
+	day, err := time.ParseInLocation("2006-01-02", s.Expires, time.Local)
+	if err != nil {
+		return fmt.Errorf("失效日期 '%s' 格式无效，应为 YYYY-MM-DD", s.Expires)
+	}
+	s.expiresAt = day.AddDate(0, 0, 1)
+	if s.MatchRegex != "" {
+		if s.re, err = regexp.Compile(s.MatchRegex); err != nil {
+			return fmt.Errorf("正则表达式 '%s' 无效: %w", s.MatchRegex, err)
+		}
+	}
+	return nil
+}
+
+// Expired 判断抑制规则在给定时间是否已失效
+func (s Suppression) Expired(now time.Time) bool {
+	return !now.Before(s.expiresAt)
+}
+
+// String 返回抑制规则的匹配条件摘要
+func (s Suppression) String() string {
+	var parts []string
+	for _, kv := range [][2]string{{"rule", s.Rule}, {"check", s.Check}, {"field", s.Field}, {"match", s.Match}, {"match_regex", s.MatchRegex}, {"host", s.Host}} {
+		if kv[1] != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", kv[0], kv[1]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// checkLevel 判断抑制规则是否屏蔽整个检查项，即只指定了 check (和 host)，没有针对具体发现的匹配条件
+func (s Suppression) checkLevel() bool {
+	return s.Rule == "" && s.Field == "" && s.Match == "" && s.MatchRegex == ""
+}
+
+// matches 判断发现是否满足抑制规则的全部匹配条件
+func (s Suppression) matches(checkName, hostname string, f Finding) bool {
+	if s.Check != "" && s.Check != checkName {
+		return false
+	}
+	if s.Rule != "" {
+		if ok, _ := path.Match(s.Rule, f.Name); !ok {
+			return false
+		}
+	}
+	if s.Host != "" {
+		if ok, _ := path.Match(s.Host, hostname); !ok {
+			return false
+		}
+	}
+	value := f.MatchedLine
+	if s.Field != "" {
+		var ok bool
+		if value, ok = f.Record[s.Field]; !ok {
+			return false
+		}
+	}
+	if s.Match != "" && !strings.Contains(value, s.Match) {
+		return false
+	}
+	return s.re == nil || s.re.MatchString(value)
+}
+
+// ReadSuppressions 读取并编译抑制规则文件，返回有效的抑制规则和每条无效规则的错误
+func ReadSuppressions(filePath string) ([]Suppression, []error, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file SuppressionFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, nil, fmt.Errorf("无法解析抑制规则文件 '%s': %w", filePath, err)
+	}
+	var valid []Suppression
+	var invalid []error
+	for i, s := range file.Suppressions {
+		if err := s.Compile(); err != nil {
+			invalid = append(invalid, fmt.Errorf("第 %d 条抑制规则 (%s): %w", i+1, s.String(), err))
+			continue
+		}
+		valid = append(valid, s)
+	}
+	return valid, invalid, nil
+}
+
+// LoadSuppressions 加载抑制规则文件。文件不存在时不启用抑制；无效的抑制规则会被跳过，
+// 已过期的抑制规则不会生效，可通过 ExpiredSuppressions 获取并在报告中列出
+func (e *RuleEngine) LoadSuppressions(filePath, hostname string, now time.Time) error {
+	if filePath == "" {
+		return nil
+	}
+	suppressions, invalid, err := ReadSuppressions(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, err := range invalid {
+		fmt.Printf("警告: 抑制规则无效, 已跳过: %v\n", err)
+	}
+	e.hostname = hostname
+	e.suppressions, e.expiredSuppressions = nil, nil
+	for _, s := range suppressions {
+		if s.Expired(now) {
+			e.expiredSuppressions = append(e.expiredSuppressions, s)
+		} else {
+			e.suppressions = append(e.suppressions, s)
+		}
+	}
+	return nil
+}
+
+// ExpiredSuppressions 返回已过期而未生效的抑制规则
+func (e *RuleEngine) ExpiredSuppressions() []Suppression {
+	return e.expiredSuppressions
+}
+
+// Suppress 使用抑制规则过滤检查项的发现，返回保留的发现和被抑制的发现
+func (e *RuleEngine) Suppress(checkName string, findings []Finding) (kept []Finding, suppressed []SuppressedFinding) {
+	for _, f := range findings {
+		matched := false
+		for _, s := range e.suppressions {
+			if s.matches(checkName, e.hostname, f) {
+				suppressed = append(suppressed, SuppressedFinding{Finding: f, Reason: s.Reason, Owner: s.Owner, Expires: s.Expires})
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			kept = append(kept, f)
+		}
+	}
+	return kept, suppressed
+}
+
+// SuppressCheck 使用屏蔽整个检查项的抑制规则 (只指定了 check 和 host) 过滤没有具体发现的可疑结果。
+// 匹配时返回以结果描述作为匹配内容的被抑制发现
+func (e *RuleEngine) SuppressCheck(checkName, result string) (SuppressedFinding, bool) {
+	f := Finding{Source: "Check", Name: checkName, MatchedLine: result}
+	for _, s := range e.suppressions {
+		if s.checkLevel() && s.matches(checkName, e.hostname, f) {
+			return SuppressedFinding{Finding: f, Reason: s.Reason, Owner: s.Owner, Expires: s.Expires}, true
+		}
+	}
+	return SuppressedFinding{}, false
+}
@@ -2,6 +2,9 @@
 
 package rules
 
+// YaraEnabled 表示当前构建是否启用了YARA扫描
+const YaraEnabled = false
+
 // initYara 的存根实现，在禁用YARA时不执行任何操作
 func initYara(engine *RuleEngine, rulesDir string) {
 	// Do nothing
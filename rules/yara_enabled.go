@@ -10,6 +10,9 @@ import (
 	yara "github.com/hillu/go-yara/v4"
 )
 
+// YaraEnabled 表示当前构建是否启用了YARA扫描
+const YaraEnabled = true
+
 // initYara 在启用YARA时，负责初始化YARA编译器
 func initYara(engine *RuleEngine, rulesDir string) {
 	compiler, err := yara.NewCompiler()
@@ -47,7 +50,7 @@ func (e *RuleEngine) ScanFileWithYara(filePath string) []Finding {
 		return findings
 	}
 
-	rules, err := e.yaraCompiler.GetRules()
+	rules, err := compiler.GetRules()
 	if err != nil {
 		return findings
 	}
@@ -0,0 +1,83 @@
+// Package scoring 根据检查结果计算主机风险评分
+package scoring
+
+import (
+	"math"
+	"sort"
+
+	"github.com/keepsea/goDetect/config"
+	"github.com/keepsea/goDetect/core"
+	"github.com/keepsea/goDetect/types"
+)
+
+// Compute 计算主机风险评分。每条发现的得分为其风险等级的权重乘以所属检查项分类的系数；
+// 同名发现 (如同一条规则多次命中) 重复出现时得分按 RepeatDecay 逐条递减，避免一条嘈杂的规则主导评分。
+// 被抑制的发现不计分；状态为可疑但没有具体发现的检查项按 CheckRisk 计一条发现，
+// 其权重应低于可疑阈值，使单个这样的检查项不足以改变结论；CheckRisk 未配置权重时不计分
+func Compute(results []types.CheckResult, cfg config.ScoringConfig) types.HostScore {
+	seen := make(map[string]int) // 发现名称 -> 已计分的次数
+	byKey := make(map[[4]string]*types.ScoreContribution)
+	var order [][4]string
+	var raw float64
+
+	add := func(r types.CheckResult, source, name, risk string) {
+		weight, ok := cfg.RiskWeights[risk]
+		if !ok {
+			risk = cfg.DefaultRisk
+			weight = cfg.RiskWeights[risk]
+		}
+		if reg, ok := core.Lookup(r.CheckName); ok {
+			if factor, ok := cfg.CategoryWeights[reg.Category]; ok {
+				weight *= factor
+			}
+		}
+		points := weight * math.Pow(cfg.RepeatDecay, float64(seen[source+"|"+name]))
+		seen[source+"|"+name]++
+		raw += points
+
+		container := ""
+		if r.Container != nil {
+			container = r.Container.Name
+		}
+		key := [4]string{r.CheckName, container, name, risk}
+		c, ok := byKey[key]
+		if !ok {
+			c = &types.ScoreContribution{CheckName: r.CheckName, Container: container, Name: name, RiskLevel: risk}
+			byKey[key] = c
+			order = append(order, key)
+		}
+		c.Count++
+		c.Points += points
+	}
+
+	for _, r := range results {
+		if len(r.Findings) == 0 {
+			if _, ok := cfg.RiskWeights[cfg.CheckRisk]; ok && r.Status == types.StatusSuspicious {
+				add(r, "Check", r.Result, cfg.CheckRisk)
+			}
+			continue
+		}
+		for _, f := range r.Findings {
+			add(r, f.Source, f.Name, f.RiskLevel)
+		}
+	}
+
+	score := types.HostScore{Score: int(math.Min(100, math.Round(raw)))}
+	switch {
+	case raw > 0 && score.Score >= cfg.Thresholds.Compromised:
+		score.Verdict = types.VerdictCompromised
+	case raw > 0 && score.Score >= cfg.Thresholds.Suspicious:
+		score.Verdict = types.VerdictSuspicious
+	default:
+		score.Verdict = types.VerdictClean
+	}
+	for _, key := range order {
+		c := byKey[key]
+		c.Points = math.Round(c.Points*10) / 10
+		score.Contributions = append(score.Contributions, *c)
+	}
+	sort.SliceStable(score.Contributions, func(i, j int) bool {
+		return score.Contributions[i].Points > score.Contributions[j].Points
+	})
+	return score
+}
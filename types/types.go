@@ -2,6 +2,37 @@ package types
 
 import "github.com/keepsea/goDetect/rules"
 
+// Status 表示单项检查的结果状态
+type Status string
+
+const (
+	StatusOK         Status = "ok"         // 检查完成，未发现异常
+	StatusSuspicious Status = "suspicious" // 检查完成，发现可疑项
+	StatusError      Status = "error"      // 检查失败，未能获取数据
+	StatusSkipped    Status = "skipped"    // 检查未执行
+	StatusDegraded   Status = "degraded"   // 检查完成，但部分数据源不可用，结果可能不完整
+	StatusTimedOut   Status = "timed_out"  // 检查超时或被取消，仅包含部分输出
+)
+
+// Label 返回状态在报告中的展示文本
+func (s Status) Label() string {
+	switch s {
+	case StatusOK:
+		return "[正常]"
+	case StatusSuspicious:
+		return "**[可疑]**"
+	case StatusError:
+		return "**[错误]**"
+	case StatusSkipped:
+		return "[跳过]"
+	case StatusDegraded:
+		return "[不完整]"
+	case StatusTimedOut:
+		return "**[超时]**"
+	}
+	return "[" + string(s) + "]"
+}
+
 // ReportData 结构体用于存储所有检测结果，并传递给模板
 type ReportData struct {
 	Timestamp       string
@@ -11,15 +42,179 @@ type ReportData struct {
 	GeneratedBy     string
 	TotalChecks     int
 	SuspiciousCount int
+	ErrorCount      int
+	SkippedCount    int
+	DegradedCount   int
+	TimedOutCount   int
+
+	SuppressedCount     int                 // 被抑制规则屏蔽的发现总数
+	ExpiredSuppressions []rules.Suppression // 已过期而未生效的抑制规则
+
+	AttackView       []TacticGroup // 按 ATT&CK 战术分组的风险发现，按矩阵顺序排列
+	UnmappedFindings int           // 未关联 ATT&CK 战术或技术的风险发现数量
+
+	Score HostScore // 主机风险评分及结论
+}
+
+// Verdict 表示根据风险评分得出的主机结论
+type Verdict string
+
+const (
+	VerdictClean       Verdict = "clean"              // 未发现值得关注的风险
+	VerdictSuspicious  Verdict = "suspicious"         // 存在需要人工研判的风险
+	VerdictCompromised Verdict = "likely_compromised" // 很可能已经失陷
+)
+
+// Label 返回结论在报告中的展示文本
+func (v Verdict) Label() string {
+	switch v {
+	case VerdictClean:
+		return "干净"
+	case VerdictSuspicious:
+		return "可疑"
+	case VerdictCompromised:
+		return "疑似失陷"
+	}
+	return string(v)
+}
+
+// ExitCode 返回结论对应的进程退出码。1 和 2 分别被严重错误和参数错误占用，因此可疑和疑似失陷使用 3 和 4
+func (v Verdict) ExitCode() int {
+	switch v {
+	case VerdictSuspicious:
+		return 3
+	case VerdictCompromised:
+		return 4
+	}
+	return 0
+}
+
+// HostScore 是主机的风险评分，由各发现按风险等级和检查项分类加权累计而成，上限为 100
+type HostScore struct {
+	Score         int
+	Verdict       Verdict
+	Contributions []ScoreContribution // 按得分从高到低排列的计分来源
+}
+
+// ScoreContribution 是同一检查项中同名发现对评分的贡献
+type ScoreContribution struct {
+	CheckName string
+	Container string `json:",omitempty"`
+	Name      string // 发现名称，没有具体发现的可疑检查项为检查结果描述
+	RiskLevel string
+	Count     int
+	Points    float64
+}
+
+// CountStatuses 根据 Checks 统计各状态的数量
+func (d *ReportData) CountStatuses() {
+	d.TotalChecks = len(d.Checks)
+	d.SuspiciousCount, d.ErrorCount, d.SkippedCount, d.DegradedCount, d.TimedOutCount = 0, 0, 0, 0, 0
+	d.SuppressedCount = 0
+	for _, check := range d.Checks {
+		d.SuppressedCount += len(check.Suppressed)
+		switch check.Status {
+		case StatusSuspicious:
+			d.SuspiciousCount++
+		case StatusError:
+			d.ErrorCount++
+		case StatusSkipped:
+			d.SkippedCount++
+		case StatusDegraded:
+			d.DegradedCount++
+		case StatusTimedOut:
+			d.TimedOutCount++
+		}
+	}
+}
+
+// TacticGroup 是归属于同一 ATT&CK 战术的风险发现
+type TacticGroup struct {
+	rules.Tactic
+	Findings []TacticFinding
+}
+
+// TacticFinding 是战术视图中的一条风险发现及其来源
+type TacticFinding struct {
+	CheckName string
+	Container string `json:",omitempty"` // 结果所属容器的名称，宿主机上的结果为空
+	rules.Finding
+}
+
+// GroupByTactic 将各检查项的风险发现按 ATT&CK 战术分组，关联多个战术的发现在每个战术下各出现一次；
+// 只声明了技术的发现归入 rules.UnknownTactic
+func (d *ReportData) GroupByTactic() {
+	groups := make(map[string]*TacticGroup)
+	d.AttackView, d.UnmappedFindings = nil, 0
+	for _, check := range d.Checks {
+		for _, f := range check.Findings {
+			if f.Attack == nil || f.Attack.IsZero() {
+				d.UnmappedFindings++
+				continue
+			}
+			tf := TacticFinding{CheckName: check.CheckName, Finding: f}
+			if check.Container != nil {
+				tf.Container = check.Container.Name
+			}
+			tactics := f.Attack.Tactics
+			if len(tactics) == 0 {
+				tactics = []string{rules.UnknownTactic.Name}
+			}
+			for _, name := range tactics {
+				g, ok := groups[name]
+				if !ok {
+					t, found := rules.LookupTactic(name)
+					if !found {
+						t = rules.UnknownTactic
+					}
+					g = &TacticGroup{Tactic: t}
+					groups[name] = g
+				}
+				g.Findings = append(g.Findings, tf)
+			}
+		}
+	}
+	for _, t := range append(append([]rules.Tactic{}, rules.Tactics...), rules.UnknownTactic) {
+		if g, ok := groups[t.Name]; ok {
+			d.AttackView = append(d.AttackView, *g)
+		}
+	}
 }
 
 // CheckResult 结构体用于存储单项检查的结果
 type CheckResult struct {
-	Category     string
-	Description  string
-	Result       string
-	Details      string
-	Explanation  string
-	IsSuspicious bool
-	Findings     []rules.Finding // 用于存放规则匹配结果
+	CheckName   string // 产生该结果的检查项编程名称，由执行器填充
+	Category    string
+	Description string
+	Status      Status
+	Result      string
+	Details     string
+	Explanation string
+	Findings    []rules.Finding           // 用于存放规则匹配结果
+	Suppressed  []rules.SuppressedFinding `json:",omitempty"` // 被抑制规则屏蔽的发现
+	Container   *ContainerInfo            `json:",omitempty"` // 结果所属的容器，宿主机上的结果为 nil
+	Artifacts   []Artifact                `json:"-"`          // 规范化的采集数据，用于基线保存与比对，不输出到报告
+}
+
+// ContainerInfo 标识检查结果所属的容器
+type ContainerInfo struct {
+	ID      string
+	Name    string
+	Image   string
+	Runtime string
+	PID     int // 用于访问容器文件系统的宿主机进程PID
+}
+
+// ShortID 返回容器ID的前12位，与 docker ps 的展示一致
+func (c ContainerInfo) ShortID() string {
+	if len(c.ID) > 12 {
+		return c.ID[:12]
+	}
+	return c.ID
+}
+
+// Artifact 是检查项采集到的一条规范化数据，用于基线比对
+type Artifact struct {
+	Key   string // 唯一标识，如监听地址、文件路径、用户名
+	Value string // 用于判断变更的属性摘要，为空表示只比较是否存在
 }
@@ -2,20 +2,43 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
+	"syscall"
 )
 
 // RunCommand 辅助函数，用于执行shell命令并返回其输出
-func RunCommand(name string, args ...string) (string, error) {
+// 当 ctx 被取消或超时时，会杀死子进程所在的整个进程组。命令失败或被中止时同样返回已产生的标准输出，
+// 因为 find 等命令在部分目录无权限时会以非零状态退出，但其余输出仍然有效
+func RunCommand(ctx context.Context, name string, args ...string) (string, error) {
 	cmd := exec.Command(name, args...)
+	// 使用独立的进程组，确保取消时孙进程也能被一并杀死，避免其持有管道导致 Wait 永久阻塞
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
-	err := cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("命令执行失败: %s\n错误: %s", cmd.String(), err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return out.String(), fmt.Errorf("命令执行被中止: %s\n原因: %w", cmd.String(), ctxErr)
+	}
 	if err != nil {
-		return "", fmt.Errorf("命令执行失败: %s\n错误: %s\n标准错误输出: %s", cmd.String(), err, stderr.String())
+		return out.String(), fmt.Errorf("命令执行失败: %s\n错误: %s\n标准错误输出: %s", cmd.String(), err, stderr.String())
 	}
 	return out.String(), nil
 }
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks 是解析一个路径时最多跟随的符号链接数，与 Linux 的 MAXSYMLINKS 相同
+const maxSymlinks = 40
+
+// HostPath 将被检查系统中的绝对路径映射到实际读取的路径。
+// root 为空或为 "/" 时表示检查当前运行的系统，否则路径会被重定位到 root 之下 (如挂载的磁盘镜像)。
+// 路径中的符号链接在 root 之内逐段解析，绝对路径的链接目标 (如 /var/run -> /run) 和 ".." 都相对于 root，
+// 因此镜像或容器中的链接不会指向扫描主机自身的文件
+func HostPath(root, path string) string {
+	if root == "" || root == "/" {
+		return path
+	}
+	resolved, ok := resolveInRoot(root, path)
+	if !ok {
+		// 符号链接过多 (通常是循环链接)。返回一个无法打开的路径，而不是交给系统在 root 之外继续解析
+		return filepath.Join(root, resolved) + "\x00"
+	}
+	return filepath.Join(root, resolved)
+}
+
+// resolveInRoot 在 root 之内解析路径中的符号链接，返回相对于 root 的、不含符号链接的绝对路径。
+// 不存在的部分按字面拼接。跟随的链接超过 maxSymlinks 个时返回 false
+func resolveInRoot(root, path string) (string, bool) {
+	resolved := "/"
+	remaining := path
+	links := 0
+	for remaining != "" {
+		var part string
+		if i := strings.IndexByte(remaining, '/'); i >= 0 {
+			part, remaining = remaining[:i], remaining[i+1:]
+		} else {
+			part, remaining = remaining, ""
+		}
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return next, false
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + "/" + remaining
+	}
+	return resolved, true
+}
+
+// StripRoot 去除命令输出中的 root 前缀，使离线扫描的结果路径与在线扫描保持一致
+func StripRoot(root, s string) string {
+	if root == "" || root == "/" {
+		return s
+	}
+	return strings.ReplaceAll(s, strings.TrimRight(root, "/")+"/", "/")
+}
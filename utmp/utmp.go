@@ -0,0 +1,157 @@
+package utmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+)
+
+// RecordType 对应 utmp 记录中的 ut_type 字段
+type RecordType int16
+
+const (
+	Empty        RecordType = 0
+	RunLevel     RecordType = 1
+	BootTime     RecordType = 2
+	NewTime      RecordType = 3
+	OldTime      RecordType = 4
+	InitProcess  RecordType = 5
+	LoginProcess RecordType = 6
+	UserProcess  RecordType = 7 // 用户登录
+	DeadProcess  RecordType = 8 // 会话结束
+	Accounting   RecordType = 9
+)
+
+var typeNames = map[RecordType]string{
+	Empty: "EMPTY", RunLevel: "RUN_LVL", BootTime: "BOOT_TIME", NewTime: "NEW_TIME", OldTime: "OLD_TIME",
+	InitProcess: "INIT_PROCESS", LoginProcess: "LOGIN_PROCESS", UserProcess: "USER_PROCESS",
+	DeadProcess: "DEAD_PROCESS", Accounting: "ACCOUNTING",
+}
+
+func (t RecordType) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE(%d)", int16(t))
+}
+
+// Linux glibc 的 struct utmp 布局 (x86_64、aarch64 等平台一致，时间字段为32位):
+//
+//	ut_type int16, pad int16, ut_pid int32, ut_line [32]byte, ut_id [4]byte, ut_user [32]byte,
+//	ut_host [256]byte, ut_exit [2]int16, ut_session int32, ut_tv [2]int32, ut_addr_v6 [4]int32, unused [20]byte
+const recordSize = 384
+
+// lastlog 中每个UID占用一条记录: ll_time int32, ll_line [32]byte, ll_host [256]byte
+const lastlogSize = 292
+
+// Record 是 utmp、wtmp 或 btmp 中的一条登录记录
+type Record struct {
+	Type RecordType
+	PID  int
+	Line string // 终端，如 pts/0
+	User string
+	Host string // 登录来源主机名或地址，本地登录为空
+	Addr netip.Addr
+	Time time.Time
+}
+
+// SourceIP 返回登录来源IP。优先使用记录中的地址字段，其次尝试将主机名解析为IP字面量
+func (r Record) SourceIP() (netip.Addr, bool) {
+	if r.Addr.IsValid() && !r.Addr.IsUnspecified() {
+		return r.Addr, true
+	}
+	if addr, err := netip.ParseAddr(r.Host); err == nil {
+		return addr, true
+	}
+	return netip.Addr{}, false
+}
+
+// ReadFile 解析 utmp 格式的文件 (如 /var/run/utmp、/var/log/wtmp、/var/log/btmp)，按文件中的顺序返回记录。
+// 文件末尾不完整的记录会被忽略
+func ReadFile(path string) ([]Record, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for off := 0; off+recordSize <= len(content); off += recordSize {
+		records = append(records, parseRecord(content[off:off+recordSize]))
+	}
+	return records, nil
+}
+
+func parseRecord(b []byte) Record {
+	le := binary.LittleEndian
+	r := Record{
+		Type: RecordType(int16(le.Uint16(b[0:]))),
+		PID:  int(int32(le.Uint32(b[4:]))),
+		Line: cString(b[8:40]),
+		User: cString(b[44:76]),
+		Host: cString(b[76:332]),
+		Time: time.Unix(int64(int32(le.Uint32(b[340:]))), int64(int32(le.Uint32(b[344:])))*1000),
+	}
+	// ut_addr_v6: IPv4 地址只占用第一个32位字，以网络字节序存储。仅凭后12个字节为零无法区分 IPv4 地址和
+	// 2001:db8:: 这样的 IPv6 地址，因此 ut_host 为 IPv6 字面量时总是按 IPv6 解析
+	addr := b[348:364]
+	host, err := netip.ParseAddr(r.Host)
+	isV6Host := err == nil && host.Is6() && !host.Is4In6()
+	if !isV6Host && bytes.Equal(addr[4:], make([]byte, 12)) {
+		r.Addr = netip.AddrFrom4([4]byte{addr[0], addr[1], addr[2], addr[3]})
+	} else {
+		var a16 [16]byte
+		copy(a16[:], addr)
+		r.Addr = netip.AddrFrom16(a16)
+	}
+	return r
+}
+
+// LastlogEntry 是 lastlog 中某个UID的最近一次登录
+type LastlogEntry struct {
+	UID  int
+	Line string
+	Host string
+	Time time.Time
+}
+
+// ReadLastlog 读取 /var/log/lastlog 中指定UID的最近登录记录，从未登录过的UID不会出现在结果中。
+// lastlog 是以UID为索引的稀疏文件，存在大UID时文件的逻辑大小可达数百GB，因此只按偏移读取所需的记录
+func ReadLastlog(path string, uids []int) ([]LastlogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []LastlogEntry
+	b := make([]byte, lastlogSize)
+	for _, uid := range uids {
+		if uid < 0 {
+			continue
+		}
+		if _, err := f.ReadAt(b, int64(uid)*lastlogSize); err != nil {
+			continue // 超出文件末尾，说明该UID从未登录
+		}
+		sec := int32(binary.LittleEndian.Uint32(b[0:]))
+		if sec == 0 {
+			continue
+		}
+		entries = append(entries, LastlogEntry{
+			UID:  uid,
+			Line: cString(b[4:36]),
+			Host: cString(b[36:292]),
+			Time: time.Unix(int64(sec), 0),
+		})
+	}
+	return entries, nil
+}
+
+// cString 将以 NUL 结尾的定长字段转换为字符串
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -2,44 +2,107 @@
 package validation
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/keepsea/goDetect/core"
+	"github.com/keepsea/goDetect/rules"
 	"gopkg.in/yaml.v3"
 )
 
-// Rule 和 IOC 结构体定义，仅用于验证，与 rules/engine.go 解耦
-type Rule struct {
-	Name        string   `yaml:"name"`
-	Enabled     bool     `yaml:"enabled"`
-	TargetCheck string   `yaml:"target_check"`
-	Type        string   `yaml:"type"`
-	Patterns    []string `yaml:"patterns"`
-	Pattern     string   `yaml:"pattern"`
+// ruleTypes 是规则引擎支持的规则类型
+var ruleTypes = []string{"keyword", "regex", "agg_regex", "condition"}
+
+// riskLevels 是风险等级的取值，与评分模型的 risk_weights 对应
+var riskLevels = []string{"Low", "Medium", "High", "Critical"}
+
+// iocTypes 是检查项会用来匹配的 IOC 类型，其他类型的 IOC 虽能加载，但永远不会命中
+var iocTypes = []string{"ip", "domain", "url", "filename", "history_keyword", "md5", "sha1", "sha256"}
+
+// iocMatchTypes 是 IOC 的匹配方式，为空时按关键词匹配
+var iocMatchTypes = []string{"keyword", "regex", "exact"}
+
+// aggConditionFields 是 agg_regex 规则的条件表达式可以使用的统计字段
+var aggConditionFields = []string{"count", "distinct", "entity", "first", "last"}
+
+// Issue 是验证发现的一个问题
+type Issue struct {
+	Level   string `json:"level"` // error 或 warning
+	File    string `json:"file,omitempty"`
+	Name    string `json:"name,omitempty"` // 规则或 IOC 的名称
+	Message string `json:"message"`
+}
+
+// TestResult 是一条规则或 IOC 的内嵌测试用例的执行结果
+type TestResult struct {
+	Kind     string   `json:"kind"` // Rule 或 IOC
+	Name     string   `json:"name"`
+	File     string   `json:"file"`
+	Cases    int      `json:"cases"`
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// Coverage 是规则集的 ATT&CK 覆盖情况
+type Coverage struct {
+	Tactics    map[string][]string `json:"tactics"`    // 战术名称 -> 覆盖该战术的规则
+	Techniques map[string][]string `json:"techniques"` // 技术编号 -> 覆盖该技术的规则
+	Unmapped   []string            `json:"unmapped"`   // 未声明 ATT&CK 映射的规则
+}
+
+// Result 是一次验证的完整结果，可以 JSON 格式保存，供 CI 使用
+type Result struct {
+	Valid       bool         `json:"valid"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	TestsPassed int          `json:"tests_passed"`
+	TestsFailed int          `json:"tests_failed"`
+	Issues      []Issue      `json:"issues"`
+	Tests       []TestResult `json:"tests"`
+	Coverage    Coverage     `json:"attack_coverage"`
+}
+
+// validator 在验证过程中输出可读的结果，同时收集问题和测试结果
+type validator struct {
+	result    Result
+	file      string            // 正在验证的文件
+	ruleNames map[string]string // 规则名称 -> 首次定义所在的文件，用于发现重名的规则
+	coverage  *attackCoverage
 }
 
-type RuleFile struct {
-	Rules []Rule `yaml:"rules"`
+func (v *validator) errorf(name, format string, args ...interface{}) {
+	v.add("error", name, fmt.Sprintf(format, args...))
 }
 
-type IOC struct {
-	Name       string   `yaml:"name"`
-	Enabled    bool     `yaml:"enabled"`
-	Type       string   `yaml:"type"`
-	MatchType  string   `yaml:"match_type"`
-	Indicators []string `yaml:"indicators"`
+func (v *validator) warnf(name, format string, args ...interface{}) {
+	v.add("warning", name, fmt.Sprintf(format, args...))
 }
 
-type IOCFile struct {
-	IOCs []IOC `yaml:"iocs"`
+func (v *validator) add(level, name, message string) {
+	if level == "error" {
+		fmt.Printf("  ERROR: %s\n", message)
+		v.result.Errors++
+	} else {
+		fmt.Printf("  WARNING: %s\n", message)
+		v.result.Warnings++
+	}
+	v.result.Issues = append(v.result.Issues, Issue{Level: level, File: v.file, Name: name, Message: message})
 }
 
-// ValidateRules 是验证功能的主函数
-func ValidateRules(rulesDir string, iocPath string) bool {
+// ValidateRules 是验证功能的主函数。jsonPath 不为空时，将验证结果以 JSON 格式保存到该文件
+func ValidateRules(rulesDir string, iocPath string, suppressionsPath string, iocFeeds []string, jsonPath string) bool {
 	fmt.Println("--- Starting Rule and IOC Validation ---")
-	var errorCount int
+	v := &validator{ruleNames: make(map[string]string), coverage: newAttackCoverage()}
 
 	// 1. 验证 YAML 规则文件
 	yamlFiles, _ := filepath.Glob(filepath.Join(rulesDir, "*.yaml"))
@@ -50,71 +113,564 @@ func ValidateRules(rulesDir string, iocPath string) bool {
 		if filePath == "" {
 			continue
 		}
+		v.validateRuleFile(filePath)
+	}
+
+	// 2. 调用YARA验证函数 (它将在其他文件中被定义)
+	v.validateYaraRules(rulesDir)
+
+	// 2.1 验证 Sigma 规则，无法转换的规则在扫描时会被跳过，只作为警告
+	v.validateSigmaRules(filepath.Join(rulesDir, "sigma"))
+
+	// 3. 验证 IOC 文件
+	v.validateIOCFile(iocPath)
+
+	// 3.1 验证 STIX / MISP 情报源
+	v.validateIOCFeeds(iocFeeds)
+
+	// 4. 验证抑制规则文件
+	if suppressionsPath != "" {
+		v.validateSuppressions(suppressionsPath)
+	}
+
+	v.coverage.print()
+	v.result.Coverage = v.coverage.summary()
+	v.printTests()
+
+	fmt.Println("--- Validation Finished ---")
+	errorCount := v.result.Errors + v.result.TestsFailed
+	v.result.Valid = errorCount == 0
+	if jsonPath != "" {
+		if err := v.result.WriteJSON(jsonPath); err != nil {
+			fmt.Printf("ERROR: Failed to write JSON result: %v\n", err)
+			return false
+		}
+		fmt.Printf("JSON result written to %s\n", jsonPath)
+	}
+	if errorCount > 0 {
+		fmt.Printf("Result: Found %d error(s).\n", errorCount)
+		return false
+	}
+
+	fmt.Println("Result: All rule and IOC files are valid.")
+	return true
+}
+
+// WriteJSON 将验证结果以 JSON 格式写入文件
+func (r Result) WriteJSON(path string) error {
+	if r.Issues == nil {
+		r.Issues = []Issue{}
+	}
+	if r.Tests == nil {
+		r.Tests = []TestResult{}
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// decodeStrict 严格解析 YAML 文件，未知字段和类型不符的字段计为错误，其余内容仍会被解析。
+// 返回 false 表示文件存在语法错误，无法继续验证
+func (v *validator) decodeStrict(content []byte, out interface{}) bool {
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	err := dec.Decode(out)
+	if err == nil || err == io.EOF {
+		return true
+	}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			v.errorf("", "Schema: %s", schemaMessage(msg))
+		}
+		return true
+	}
+	v.errorf("", "YAML syntax error: %v", err)
+	return false
+}
+
+// unknownFieldRe 匹配 yaml.v3 报告未知字段的错误信息
+var unknownFieldRe = regexp.MustCompile(`^line (\d+): field (\S+) not found in type rules\.(\w+)$`)
+
+// schemaMessage 将 yaml.v3 的未知字段错误改写为规则作者易于理解的形式
+func schemaMessage(msg string) string {
+	m := unknownFieldRe.FindStringSubmatch(msg)
+	if m == nil {
+		return msg
+	}
+	where := map[string]string{"Rule": "rule", "RuleFile": "rule file", "IOC": "IOC", "IOCFile": "IOC file", "Attack": "attack mapping", "Tests": "tests"}[m[3]]
+	if where == "" {
+		where = m[3]
+	}
+	return fmt.Sprintf("line %s: unknown field '%s' in %s", m[1], m[2], where)
+}
+
+// validateRuleFile 验证一个规则文件。规则目录中的 IOC 文件和抑制规则文件不含 rules 键，会被跳过
+func (v *validator) validateRuleFile(filePath string) {
+	v.file = filePath
+	yamlFile, err := ioutil.ReadFile(filePath)
+	if err != nil {
 		fmt.Printf("Validating YAML file: %s\n", filePath)
-		yamlFile, err := ioutil.ReadFile(filePath)
-		if err != nil {
-			fmt.Printf("  ERROR: Failed to read file: %v\n", err)
-			errorCount++
-			continue
+		v.errorf("", "Failed to read file: %v", err)
+		return
+	}
+	var top map[string]interface{}
+	if err := yaml.Unmarshal(yamlFile, &top); err == nil {
+		if _, ok := top["rules"]; !ok {
+			for _, key := range []string{"iocs", "suppressions"} {
+				if _, ok := top[key]; ok {
+					fmt.Printf("Skipping YAML file: %s (contains '%s', not rules)\n", filePath, key)
+					return
+				}
+			}
+			fmt.Printf("Validating YAML file: %s\n", filePath)
+			v.warnf("", "File has no top-level 'rules' key and will not load any rule")
+			return
 		}
+	}
+	fmt.Printf("Validating YAML file: %s\n", filePath)
 
-		var ruleFile RuleFile
-		err = yaml.Unmarshal(yamlFile, &ruleFile)
-		if err != nil {
-			fmt.Printf("  ERROR: YAML syntax error: %v\n", err)
-			errorCount++
+	var ruleFile rules.RuleFile
+	if !v.decodeStrict(yamlFile, &ruleFile) {
+		return
+	}
+	for i, rule := range ruleFile.Rules {
+		label := fmt.Sprintf("Rule #%d ('%s')", i+1, rule.Name)
+		if rule.Name == "" {
+			v.errorf("", "Rule #%d has no name", i+1)
+		} else if first, ok := v.ruleNames[rule.Name]; ok {
+			v.errorf(rule.Name, "%s duplicates a rule name already defined in %s", label, first)
+		} else {
+			v.ruleNames[rule.Name] = filePath
+		}
+		if !rule.Enabled {
+			continue
+		}
+		errorsBefore := v.result.Errors
+		v.checkRule(label, rule)
+		if rule.Tests.Count() == 0 {
+			continue
+		}
+		if v.result.Errors > errorsBefore {
+			fmt.Printf("  TEST SKIP: Rule '%s': fix the errors above first\n", rule.Name)
 			continue
 		}
+		failures, err := rule.RunTests()
+		v.reportTests("Rule", rule.Name, rule.Tests.Count(), failures, err)
+	}
+}
 
-		for i, rule := range ruleFile.Rules {
-			if !rule.Enabled {
+// checkRule 验证一条已启用规则的取值和语义
+func (v *validator) checkRule(label string, rule rules.Rule) {
+	name := rule.Name
+	// 验证目标检查项、类型和风险等级
+	reg, known := core.Lookup(rule.TargetCheck)
+	if rule.TargetCheck == "" {
+		v.errorf(name, "%s has no target_check", label)
+	} else if !known {
+		v.errorf(name, "%s targets unknown check '%s' (see -list-checks)", label, rule.TargetCheck)
+	}
+	if !contains(ruleTypes, rule.Type) {
+		v.errorf(name, "%s has unknown type '%s' (expected one of: %s)", label, rule.Type, strings.Join(ruleTypes, ", "))
+	}
+	if rule.RiskLevel == "" {
+		v.errorf(name, "%s has no risk_level", label)
+	} else if !contains(riskLevels, rule.RiskLevel) {
+		v.errorf(name, "%s has invalid risk_level '%s' (expected one of: %s)", label, rule.RiskLevel, strings.Join(riskLevels, ", "))
+	}
+	// 验证匹配模式
+	switch rule.Type {
+	case "keyword", "regex":
+		if len(rule.Patterns) == 0 {
+			v.errorf(name, "%s of type '%s' requires 'patterns'", label, rule.Type)
+		}
+		if rule.Pattern != "" {
+			v.errorf(name, "%s of type '%s' uses 'pattern', which is only read by 'agg_regex' rules; use 'patterns'", label, rule.Type)
+		}
+	case "agg_regex":
+		if rule.Pattern == "" {
+			v.errorf(name, "%s of type 'agg_regex' requires 'pattern'", label)
+		}
+		if len(rule.Patterns) > 0 {
+			v.errorf(name, "%s of type 'agg_regex' uses 'patterns'; use a single 'pattern' instead", label)
+		}
+	case "condition":
+		if len(rule.Patterns) > 0 || rule.Pattern != "" {
+			v.errorf(name, "%s of type 'condition' does not use patterns", label)
+		}
+	}
+	// 验证正则表达式
+	if rule.Type == "regex" || rule.Type == "agg_regex" {
+		patterns := rule.Patterns
+		if rule.Type == "agg_regex" {
+			patterns = []string{rule.Pattern}
+		}
+		for _, p := range patterns {
+			if p == "" {
 				continue
 			}
-			// 验证正则表达式
-			if rule.Type == "regex" || rule.Type == "agg_regex" {
-				patterns := rule.Patterns
-				if rule.Type == "agg_regex" {
-					patterns = []string{rule.Pattern}
-				}
-				for _, p := range patterns {
-					if p == "" {
-						continue
-					}
-					_, err := regexp.Compile(p)
-					if err != nil {
-						fmt.Printf("  ERROR: Rule #%d ('%s') has an invalid regex pattern '%s': %v\n", i+1, rule.Name, p, err)
-						errorCount++
-					}
-				}
+			re, err := regexp.Compile(p)
+			if err != nil {
+				v.errorf(name, "%s has an invalid regex pattern '%s': %v", label, p, err)
+			} else if rule.Type == "agg_regex" && re.NumSubexp() == 0 {
+				v.errorf(name, "%s has no capture group in pattern '%s' to extract the aggregated entity", label, p)
 			}
 		}
 	}
+	// 验证条件表达式。agg_regex 条件中的聚合子句合并到规则中，与规则中直接填写的 window、distinct 和 field 一同验证
+	if rule.Condition == "" && (rule.Type == "condition" || rule.Type == "agg_regex") {
+		v.errorf(name, "%s of type '%s' requires a condition", label, rule.Type)
+	} else if rule.Type == "agg_regex" {
+		if cond, clauses, err := rules.CompileAggCondition(rule.Condition); err != nil {
+			v.errorf(name, "%s has an invalid condition '%s': %v", label, rule.Condition, err)
+		} else if err := rule.ApplyAggClauses(clauses); err != nil {
+			v.errorf(name, "%s has an invalid agg condition: %v", label, err)
+		} else {
+			v.checkConditionFields(label, rule, cond.Fields(), reg, known)
+		}
+	} else if rule.Condition != "" {
+		if cond, err := rules.CompileCondition(rule.Condition); err != nil {
+			v.errorf(name, "%s has an invalid condition '%s': %v", label, rule.Condition, err)
+		} else {
+			v.checkConditionFields(label, rule, cond.Fields(), reg, known)
+		}
+	}
+	// 验证聚合规则的时间窗口和统计字段
+	if rule.Type != "agg_regex" && (rule.Window != "" || rule.Distinct != "" || rule.TimeField != "") {
+		v.errorf(name, "%s: 'window', 'distinct' and 'time_field' are only supported by 'agg_regex' rules", label)
+	}
+	if rule.Window != "" {
+		if window, err := time.ParseDuration(rule.Window); err != nil || window <= 0 {
+			v.errorf(name, "%s has an invalid window '%s' (expected a duration such as 5m or 1h)", label, rule.Window)
+		}
+	}
+	for _, field := range []string{rule.Distinct, rule.TimeField} {
+		if known && field != "" && !reg.HasField(field) {
+			v.errorf(name, "%s aggregates unknown field '%s' of %s (available: %s)", label, field, rule.TargetCheck, strings.Join(reg.Fields, ", "))
+		}
+	}
+	// 验证 ATT&CK 映射
+	if attack, err := rule.Attack.Normalize(); err != nil {
+		v.errorf(name, "%s has an invalid ATT&CK mapping: %v", label, err)
+	} else {
+		v.coverage.add(rule.Name, attack)
+	}
+	// 验证目标字段
+	if rule.Field != "" {
+		if rule.Type == "condition" {
+			v.errorf(name, "%s of type 'condition' cannot use 'field'; reference fields in the condition instead", label)
+		} else if known && !reg.HasField(rule.Field) {
+			v.errorf(name, "%s targets unknown field '%s' of %s (available: %s)",
+				label, rule.Field, rule.TargetCheck, strings.Join(append([]string{"line"}, reg.Fields...), ", "))
+		}
+	}
+}
 
-	// 2. 调用YARA验证函数 (它将在其他文件中被定义)
-	yaraErrorCount := validateYaraRules(rulesDir)
-	errorCount += yaraErrorCount
+// checkConditionFields 验证条件表达式引用的字段。agg_regex 规则的条件只能使用统计字段，
+// 其他规则的条件使用目标检查项的记录字段
+func (v *validator) checkConditionFields(label string, rule rules.Rule, fields []string, reg core.Registration, known bool) {
+	for _, field := range fields {
+		switch {
+		case rule.Type == "agg_regex" && !contains(aggConditionFields, field):
+			v.errorf(rule.Name, "%s has an invalid agg condition: unknown field '%s' (agg conditions can use: %s, "+
+				"plus the clauses 'distinct <field>', 'per <field>' and 'within <duration>')",
+				label, field, strings.Join(aggConditionFields, ", "))
+		case rule.Type == "agg_regex" && field == "distinct" && rule.Distinct == "":
+			v.errorf(rule.Name, "%s has an invalid agg condition: 'distinct' is always 0 unless the rule sets 'distinct' or uses 'distinct <field>'", label)
+		case rule.Type != "agg_regex" && known && field != "line" && !reg.HasField(field):
+			v.errorf(rule.Name, "%s has a condition on unknown field '%s' of %s (available: %s)",
+				label, field, rule.TargetCheck, strings.Join(append([]string{"line"}, reg.Fields...), ", "))
+		}
+	}
+}
 
-	// 3. 验证 IOC 文件
+// validateIOCFile 验证 IOC 文件
+func (v *validator) validateIOCFile(iocPath string) {
+	v.file = iocPath
 	fmt.Printf("Validating IOC file: %s\n", iocPath)
 	iocFileContent, err := ioutil.ReadFile(iocPath)
 	if err != nil {
-		fmt.Printf("  ERROR: Failed to read file: %v\n", err)
-		errorCount++
+		v.errorf("", "Failed to read file: %v", err)
+		return
+	}
+	var iocFile rules.IOCFile
+	if !v.decodeStrict(iocFileContent, &iocFile) {
+		return
+	}
+	names := make(map[string]bool)
+	for i, ioc := range iocFile.IOCs {
+		label := fmt.Sprintf("IOC #%d ('%s')", i+1, ioc.Name)
+		if ioc.Name == "" {
+			v.errorf("", "IOC #%d has no name", i+1)
+		} else if names[ioc.Name] {
+			v.errorf(ioc.Name, "%s duplicates the name of another IOC", label)
+		}
+		names[ioc.Name] = true
+		if !ioc.Enabled {
+			continue
+		}
+		errorsBefore := v.result.Errors
+		v.checkIOC(label, ioc)
+		if ioc.Tests.Count() == 0 {
+			continue
+		}
+		if v.result.Errors > errorsBefore {
+			fmt.Printf("  TEST SKIP: IOC '%s': fix the errors above first\n", ioc.Name)
+			continue
+		}
+		failures, err := ioc.RunTests()
+		v.reportTests("IOC", ioc.Name, ioc.Tests.Count(), failures, err)
+	}
+}
+
+// checkIOC 验证一个已启用 IOC 的取值和指标
+func (v *validator) checkIOC(label string, ioc rules.IOC) {
+	name := ioc.Name
+	if !contains(iocTypes, ioc.Type) {
+		v.errorf(name, "%s has unknown type '%s'; no check matches it (expected one of: %s)", label, ioc.Type, strings.Join(iocTypes, ", "))
+	}
+	if ioc.MatchType != "" && !contains(iocMatchTypes, ioc.MatchType) {
+		v.errorf(name, "%s has unknown match_type '%s' (expected one of: %s)", label, ioc.MatchType, strings.Join(iocMatchTypes, ", "))
+	}
+	if len(ioc.Indicators) == 0 {
+		v.warnf(name, "%s has no indicators", label)
+	}
+	if attack, err := ioc.Attack.Normalize(); err != nil {
+		v.errorf(name, "%s has an invalid ATT&CK mapping: %v", label, err)
 	} else {
-		var iocFile IOCFile
-		err = yaml.Unmarshal(iocFileContent, &iocFile)
+		v.coverage.add("IOC:"+ioc.Name, attack)
+	}
+	for _, indicator := range ioc.Indicators {
+		switch {
+		case ioc.MatchType == "regex":
+			if _, err := regexp.Compile(indicator); err != nil {
+				v.errorf(name, "%s has an invalid regex indicator '%s': %v", label, indicator, err)
+			}
+		case ioc.Type == "ip":
+			if _, err := rules.ParseIPIndicator(indicator); err != nil {
+				v.errorf(name, "%s has an invalid IP indicator: '%s' is not an IP address or CIDR prefix", label, indicator)
+			}
+		case rules.IsHashType(ioc.Type):
+			if _, err := rules.ParseHashIndicator(ioc.Type, indicator); err != nil {
+				v.errorf(name, "%s has an invalid %s indicator: '%s' is not a hex digest of the right length", label, ioc.Type, indicator)
+			}
+		case strings.TrimSpace(indicator) == "":
+			v.errorf(name, "%s has an empty indicator", label)
+		}
+	}
+	if expired, err := (rules.IOC{ValidUntil: ioc.ValidUntil}).Expired(time.Now()); err != nil {
+		v.errorf(name, "%s has an invalid valid_until: %v", label, err)
+	} else if expired {
+		v.warnf(name, "%s expired at %s and will not be loaded", label, ioc.ValidUntil)
+	}
+}
+
+// reportTests 输出并记录一条规则或 IOC 的测试结果
+func (v *validator) reportTests(kind, name string, cases int, failures []rules.TestFailure, err error) {
+	result := TestResult{Kind: kind, Name: name, File: v.file, Cases: cases}
+	switch {
+	case err != nil:
+		result.Failures = []string{err.Error()}
+	default:
+		for _, f := range failures {
+			result.Failures = append(result.Failures, f.String())
+		}
+	}
+	for _, f := range result.Failures {
+		fmt.Printf("  TEST FAIL: %s '%s': %s\n", kind, name, f)
+	}
+	if len(result.Failures) == 0 {
+		fmt.Printf("  TEST PASS: %s '%s' (%d case(s))\n", kind, name, cases)
+		result.Passed = true
+		v.result.TestsPassed++
+	} else {
+		v.result.TestsFailed++
+	}
+	v.result.Tests = append(v.result.Tests, result)
+}
+
+func (v *validator) printTests() {
+	if len(v.result.Tests) == 0 {
+		return
+	}
+	fmt.Println("--- Rule Tests ---")
+	fmt.Printf("%d passed, %d failed\n", v.result.TestsPassed, v.result.TestsFailed)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSigmaRules 验证 Sigma 规则目录，目录不存在时跳过。无法解析的文件计为错误，无法转换的规则只提示
+func (v *validator) validateSigmaRules(sigmaDir string) {
+	if info, err := os.Stat(sigmaDir); err != nil || !info.IsDir() {
+		return
+	}
+	v.file = sigmaDir
+	fmt.Printf("Validating Sigma rules: %s\n", sigmaDir)
+	converted, issues, err := rules.LoadSigma(sigmaDir)
+	if err != nil {
+		v.errorf("", "%v", err)
+		return
+	}
+	for _, issue := range issues {
+		if issue.Invalid {
+			v.errorf("", "%s", issue)
+		} else {
+			v.warnf("", "Sigma rule cannot be converted and will be skipped: %s", issue)
+		}
+	}
+	for _, rule := range converted {
+		if first, ok := v.ruleNames[rule.Name]; ok {
+			v.errorf(rule.Name, "Sigma rule '%s' duplicates a rule name already defined in %s", rule.Name, first)
+		} else {
+			v.ruleNames[rule.Name] = sigmaDir
+		}
+		v.coverage.add(rule.Name, rule.Attack)
+	}
+	fmt.Printf("  %d Sigma rule(s) converted\n", len(converted))
+}
+
+// validateIOCFeeds 验证 STIX 2.1 / MISP 情报源，无法解析的文件计为错误，不支持的指标只提示
+func (v *validator) validateIOCFeeds(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	var files []string
+	for _, p := range paths {
+		v.file = p
+		if info, err := os.Stat(p); err != nil {
+			v.errorf("", "IOC feed: %v", err)
+		} else if info.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(p, "*.json"))
+			files = append(files, matches...)
+		} else {
+			files = append(files, p)
+		}
+	}
+	now := time.Now()
+	for _, file := range files {
+		v.file = file
+		fmt.Printf("Validating IOC feed: %s\n", file)
+		iocs, skipped, err := rules.ReadIOCFeed(file)
 		if err != nil {
-			fmt.Printf("  ERROR: YAML syntax error in IOC file: %v\n", err)
-			errorCount++
+			v.errorf("", "%v", err)
+			continue
+		}
+		indicators, expired := 0, 0
+		for _, ioc := range iocs {
+			if ok, err := ioc.Expired(now); err != nil {
+				v.errorf(ioc.Name, "IOC '%s' has an invalid valid_until: %v", ioc.Name, err)
+			} else if ok {
+				expired += len(ioc.Indicators)
+			} else {
+				indicators += len(ioc.Indicators)
+			}
 		}
+		for i, skip := range skipped {
+			if i == 10 {
+				v.warnf("", "... and %d more unsupported indicator(s)", len(skipped)-i)
+				break
+			}
+			v.warnf("", "Unsupported indicator '%s': %s", skip.Indicator, skip.Reason)
+		}
+		fmt.Printf("  %d indicator(s) loadable, %d expired, %d unsupported\n", indicators, expired, len(skipped))
 	}
+}
 
-	fmt.Println("--- Validation Finished ---")
-	if errorCount > 0 {
-		fmt.Printf("Result: Found %d error(s).\n", errorCount)
-		return false
+// attackCoverage 汇总已启用的规则和 IOC 的 ATT&CK 映射
+type attackCoverage struct {
+	attacks  map[string]rules.Attack
+	unmapped []string
+}
+
+func newAttackCoverage() *attackCoverage {
+	return &attackCoverage{attacks: make(map[string]rules.Attack)}
+}
+
+func (c *attackCoverage) add(name string, attack rules.Attack) {
+	if attack.IsZero() {
+		c.unmapped = append(c.unmapped, name)
+		return
 	}
+	c.attacks[name] = attack
+}
 
-	fmt.Println("Result: All rule and IOC files are valid.")
-	return true
+// summary 按战术和技术对规则分组，规则名称按字母顺序排列
+func (c *attackCoverage) summary() Coverage {
+	s := Coverage{Tactics: make(map[string][]string), Techniques: make(map[string][]string), Unmapped: []string{}}
+	for name, attack := range c.attacks {
+		for _, t := range attack.Tactics {
+			s.Tactics[t] = append(s.Tactics[t], name)
+		}
+		for _, t := range attack.Techniques {
+			s.Techniques[t] = append(s.Techniques[t], name)
+		}
+	}
+	for _, names := range s.Tactics {
+		sort.Strings(names)
+	}
+	for _, names := range s.Techniques {
+		sort.Strings(names)
+	}
+	s.Unmapped = append(s.Unmapped, c.unmapped...)
+	sort.Strings(s.Unmapped)
+	return s
+}
+
+// print 列出规则集覆盖的 ATT&CK 战术 (含未覆盖的战术) 和技术，以及未声明映射的规则
+func (c *attackCoverage) print() {
+	s := c.summary()
+	fmt.Println("--- ATT&CK Coverage ---")
+	fmt.Printf("Tactics (%d of %d covered):\n", len(s.Tactics), len(rules.Tactics))
+	for _, t := range rules.Tactics {
+		covered := strings.Join(s.Tactics[t.Name], ", ")
+		if covered == "" {
+			covered = "(not covered)"
+		}
+		fmt.Printf("  %s %-22s %s\n", t.ID, t.Name, covered)
+	}
+	ids := make([]string, 0, len(s.Techniques))
+	for id := range s.Techniques {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	fmt.Printf("Techniques (%d covered):\n", len(ids))
+	for _, id := range ids {
+		fmt.Printf("  %-10s %s\n", id, strings.Join(s.Techniques[id], ", "))
+	}
+	if len(s.Unmapped) > 0 {
+		fmt.Printf("Without ATT&CK mapping: %s\n", strings.Join(s.Unmapped, ", "))
+	}
+}
+
+// validateSuppressions 验证抑制规则文件，文件不存在时跳过。已过期的抑制规则只提示，不计为错误
+func (v *validator) validateSuppressions(suppressionsPath string) {
+	suppressions, invalid, err := rules.ReadSuppressions(suppressionsPath)
+	if os.IsNotExist(err) {
+		fmt.Printf("Skipping suppression validation: %s does not exist.\n", suppressionsPath)
+		return
+	}
+	v.file = suppressionsPath
+	fmt.Printf("Validating suppression file: %s\n", suppressionsPath)
+	if err != nil {
+		v.errorf("", "%v", err)
+		return
+	}
+	for _, err := range invalid {
+		v.errorf("", "%v", err)
+	}
+	now := time.Now()
+	for _, s := range suppressions {
+		if s.Expired(now) {
+			v.warnf("", "Suppression (%s) owned by %s expired on %s and is no longer applied", s.String(), s.Owner, s.Expires)
+		}
+	}
 }
@@ -11,36 +11,32 @@ import (
 )
 
 // validateYaraRules 在启用YARA时，执行真正的YARA规则验证
-func validateYaraRules(rulesDir string) int {
-	var errorCount int
+func (v *validator) validateYaraRules(rulesDir string) {
 	yaraFiles, _ := filepath.Glob(filepath.Join(rulesDir, "*.yar"))
 	yaraFiles = append(yaraFiles, filepath.Join(rulesDir, "*.yara"))
 
 	if len(yaraFiles) > 0 {
 		compiler, err := yara.NewCompiler()
 		if err != nil {
-			fmt.Println("  ERROR: Could not create YARA compiler. Is YARA library installed correctly?")
-			errorCount++
-			return errorCount
+			v.errorf("", "Could not create YARA compiler. Is YARA library installed correctly?")
+			return
 		}
 		for _, filePath := range yaraFiles {
 			if filePath == "" {
 				continue
 			}
+			v.file = filePath
 			fmt.Printf("Validating YARA file: %s\n", filePath)
 			f, err := os.Open(filePath)
 			if err != nil {
-				fmt.Printf("  ERROR: Failed to read file: %v\n", err)
-				errorCount++
+				v.errorf("", "Failed to read file: %v", err)
 				continue
 			}
 			err = compiler.AddFile(f, filepath.Base(filePath))
 			f.Close()
 			if err != nil {
-				fmt.Printf("  ERROR: YARA syntax error: %v\n", err)
-				errorCount++
+				v.errorf("", "YARA syntax error: %v", err)
 			}
 		}
 	}
-	return errorCount
 }